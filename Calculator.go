@@ -1,7 +1,20 @@
 // calculadora.go
 // Uma calculadora de linha de comando em Go com REPL,
-// suporte a + - * / ^, parênteses, funções e constantes.
-// Funções: sin, cos, tan, sqrt, log (base 10), ln, abs, floor, ceil, round, max, min
+// suporte a + - * / // ^ |, parênteses, |x| como valor absoluto, funções e constantes.
+// Funções: sin, cos, tan, sqrt, log (base 10), ln, abs, floor, ceil, round,
+// max, min, divmod_q, divmod_r, copysign, remainder, dim, cbrt, isqrt,
+// factorial, poly, polyderiv, polyeval_at_roots, cf, cf_convergent, floorm,
+// ceilm, sigma, pi_prod, det2, det3, trace2, trace3, dot2, cross2, angle2,
+// dot3, cross3, binom_pmf, binom_cdf, poisson_pmf, normal_pdf, normal_cdf,
+// qnorm, probit, chi2_ppf, t_ppf, ma, ema, bits, setbits, omega, bigomega,
+// mobius, liouville, digitsum, digitalroot, numdigits, fmod, catalan, bell,
+// lucas, fib_pair, fib, verify_identity, gradient_descent, fixed_point, to_rad,
+// to_deg, to_grad, from_grad, complexity, cumsum, cumprod, polyeval, polyfit,
+// interval, chebT, chebU, legendre, legendre_roots, lambertW, zeta, poch,
+// fallfact, digits, phase, polar, rect, if, number_format, bearing_to_math,
+// math_to_bearing, normalize_angle, solve_quadratic, primorial, prime_pi,
+// integral_approx, tabulate, to_words, roman, goldenratio_approx,
+// prime_factors, prime_factors_list
 // Constantes: pi, e
 // Variável especial: ans (resultado anterior)
 package main
@@ -9,12 +22,19 @@ package main
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"math"
+	"math/big"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 type tokenType int
@@ -27,354 +47,3709 @@ const (
 	tFunc
 	tComma
 	tIdent
+	tQuestion // ? e : do operador condicional c ? a : b
+	tColon
+	tString // texto entre aspas: só nos separadores de number_format
 )
 
 type token struct {
-	typ tokenType
-	val string
+	typ    tokenType
+	val    string
+	offset int       // posição, em bytes, do início do token na expressão original
+	argc   int       // nas chamadas de função, nº de argumentos (0 se desconhecido)
+	lazy   [][]token // argumentos que a própria função avalia (ver FuncDef.lazy)
+	cond   bool      // o if(c, a, b) veio de c ? a : b, e é assim que se escreve
+}
+
+// posError é um erro associado a uma posição (em bytes) da expressão.
+type posError struct {
+	offset int
+	err    error
+}
+
+func (e *posError) Error() string { return e.err.Error() }
+func (e *posError) Unwrap() error { return e.err }
+
+// ErrorFormatter cria os erros de sintaxe e de avaliação, para quem usa a
+// calculadora como biblioteca e quer outras mensagens (traduções, códigos de
+// erro, registo estruturado). Fica em EvalContext.errors; se for nil usam-se
+// as mensagens do catálogo (defaultErrors). A posição junta-se depois com
+// errAt, por isso os erros devolvidos não precisam de a incluir.
+type ErrorFormatter interface {
+	// TokenizeError: caractere r inválido na posição offset.
+	TokenizeError(offset int, r rune) error
+	// ParseError: apareceu got onde se esperava expected, ex.: got ")" e
+	// expected "(" num parêntese fechado a mais.
+	ParseError(got, expected string) error
+	// EvalError: o operador op falhou com estes operandos (poucos operandos,
+	// ou divisão por zero).
+	EvalError(op string, args []float64) error
+}
+
+// defaultErrors dá as mensagens de sempre, na língua atual.
+type defaultErrors struct{}
+
+func (defaultErrors) TokenizeError(_ int, r rune) error {
+	return fmt.Errorf(msg("invalid_char"), r)
+}
+
+func (defaultErrors) ParseError(got, _ string) error {
+	switch got {
+	case ",":
+		return errors.New(msg("comma_outside_func"))
+	case "?":
+		return errors.New(msg("ternary_no_colon"))
+	case ":":
+		return errors.New(msg("ternary_no_question"))
+	}
+	return errors.New(msg("unbalanced_parens"))
+}
+
+func (defaultErrors) EvalError(op string, args []float64) error {
+	switch {
+	case ops[op].unary && len(args) == 0:
+		return errors.New(msg("unary_no_operand"))
+	case len(args) < 2:
+		return errors.New(msg("binary_few_operands"))
+	}
+	return errors.New(msg("division_by_zero")) // o único erro de um operador com operandos
+}
+
+// errorFormatter devolve o ErrorFormatter do contexto (ctx pode ser nil).
+func errorFormatter(ctx *EvalContext) ErrorFormatter {
+	if ctx == nil || ctx.errors == nil {
+		return defaultErrors{}
+	}
+	return ctx.errors
+}
+
+// errAt associa a posição de um token a um erro.
+func errAt(offset int, err error) error {
+	return &posError{offset, err}
+}
+
+// TokenAt devolve o token que contém a posição dada (o último que começa
+// nessa posição ou antes dela), ou nil. Os tokens estão ordenados por offset.
+func TokenAt(toks []token, offset int) *token {
+	i := sort.Search(len(toks), func(i int) bool { return toks[i].offset > offset })
+	if i == 0 {
+		return nil
+	}
+	return &toks[i-1]
 }
 
 var ops = map[string]struct {
 	prec       int
 	rightAssoc bool
 	unary      bool
+	postfix    bool // unário escrito depois do operando, como 15%
 	fn         func(a, b float64) float64
 }{
+	// comparações: 1 se verdadeiro, 0 se falso; ligam menos do que todos os outros
+	"==": {prec: -2, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a == b) }},
+	"!=": {prec: -2, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a != b) }},
+	"<":  {prec: -1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a < b) }},
+	"<=": {prec: -1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a <= b) }},
+	">":  {prec: -1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a > b) }},
+	">=": {prec: -1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return boolFloat(a >= b) }},
+	"|":  {prec: 0, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return float64(int64(a) | int64(b)) }}, // ou bit a bit
+	// 15% of 200: uma multiplicação que liga menos do que + e -
+	"of": {prec: 0, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return a * b }},
 	"+":  {prec: 1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return a + b }},
 	"-":  {prec: 1, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return a - b }},
 	"*":  {prec: 2, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return a * b }},
 	"/":  {prec: 2, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return a / b }},
-	"^":  {prec: 3, rightAssoc: true, unary: false, fn: func(a, b float64) float64 { return math.Pow(a, b) }},
-	"u-": {prec: 4, rightAssoc: true, unary: true, fn: func(_, b float64) float64 { return -b }}, // unário menos
-	"u+": {prec: 4, rightAssoc: true, unary: true, fn: func(_, b float64) float64 { return +b }},
-}
-
-var functions = map[string]func(args ...float64) (float64, error){
-	"sin": func(a ...float64) (float64, error) { return math.Sin(a[0]), nil },
-	"cos": func(a ...float64) (float64, error) { return math.Cos(a[0]), nil },
-	"tan": func(a ...float64) (float64, error) { return math.Tan(a[0]), nil },
-	"sqrt": func(a ...float64) (float64, error) {
-		if a[0] < 0 {
-			return 0, errors.New("sqrt de número negativo")
-		}
-		return math.Sqrt(a[0]), nil
-	},
-	"log":   func(a ...float64) (float64, error) { return math.Log10(a[0]), nil },
-	"ln":    func(a ...float64) (float64, error) { return math.Log(a[0]), nil },
-	"abs":   func(a ...float64) (float64, error) { return math.Abs(a[0]), nil },
-	"floor": func(a ...float64) (float64, error) { return math.Floor(a[0]), nil },
-	"ceil":  func(a ...float64) (float64, error) { return math.Ceil(a[0]), nil },
-	"round": func(a ...float64) (float64, error) { return math.Round(a[0]), nil },
-	"max": func(a ...float64) (float64, error) {
-		if len(a) < 2 {
-			return 0, errors.New("max precisa de 2 argumentos")
-		}
-		if a[0] > a[1] {
+	"//": {prec: 2, rightAssoc: false, unary: false, fn: func(a, b float64) float64 { return math.Floor(a / b) }}, // divisão inteira
+	"^":  {prec: 4, rightAssoc: true, unary: false, fn: func(a, b float64) float64 { return math.Pow(a, b) }},
+	// os unários ligam menos do que ^, como na notação matemática: -2^2 = -(2^2)
+	"u-": {prec: 3, rightAssoc: true, unary: true, fn: func(_, b float64) float64 { return -b }}, // unário menos
+	"u+": {prec: 3, rightAssoc: true, unary: true, fn: func(_, b float64) float64 { return +b }},
+	// percentagem posfixa: 15% = 0.15, e liga mais do que tudo: 2^10% = 2^(10%)
+	"%": {prec: 5, rightAssoc: false, unary: true, postfix: true, fn: func(_, b float64) float64 { return b / 100 }},
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// mathFunc é a implementação de uma função; ctx dá acesso ao estado da
+// avaliação (ex.: ctx.note para mostrar informação além do resultado).
+type mathFunc func(ctx *EvalContext, args ...float64) (float64, error)
+
+// FuncDef descreve uma função: a implementação, a aridade e a documentação
+// mostrada por :func (a descrição, por língua, está em funcDescriptions).
+type FuncDef struct {
+	name    string
+	arity   int    // negativa nas variádicas: -n é o mínimo de argumentos
+	lazy    int    // quantos dos primeiros argumentos ficam por avaliar (ver evalLazy)
+	usage   string // chave da mensagem de uso, nas funções com lazy > 0
+	sig     string // assinatura, ex.: "max(a,b)"
+	example string
+	fn      mathFunc // nil nas funções com lazy > 0
+	state   bool     // lê ou altera estado do contexto (ex.: :poly); fica fora da cache
+}
+
+// description devolve a descrição da função na língua atual.
+func (d FuncDef) description() string {
+	if s, ok := funcDescriptions[lang][d.name]; ok {
+		return s
+	}
+	return funcDescriptions["pt"][d.name]
+}
+
+func init() {
+	for name, d := range functions {
+		d.name = name
+		functions[name] = d
+	}
+}
+
+var functions = map[string]FuncDef{
+	"sin": {
+		arity: 1, sig: "sin(x)", example: "sin(pi/6)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) { return math.Sin(ctx.toRadians(a[0])), nil },
+	},
+	"cos": {
+		arity: 1, sig: "cos(x)", example: "cos(pi/3)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) { return math.Cos(ctx.toRadians(a[0])), nil },
+	},
+	"tan": {
+		arity: 1, sig: "tan(x)", example: "tan(pi/4)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) { return math.Tan(ctx.toRadians(a[0])), nil },
+	},
+	// conversões de ângulos, independentes de :deg/:rad/:grad (400 grados = 360°)
+	"to_rad": {
+		arity: 1, sig: "to_rad(graus)", example: "to_rad(180)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] * math.Pi / 180, nil },
+	},
+	"to_deg": {
+		arity: 1, sig: "to_deg(rad)", example: "to_deg(pi/2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] * 180 / math.Pi, nil },
+	},
+	"to_grad": {
+		arity: 1, sig: "to_grad(graus)", example: "to_grad(90)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] * 400 / 360, nil },
+	},
+	"from_grad": {
+		arity: 1, sig: "from_grad(grados)", example: "from_grad(50)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] * 360 / 400, nil },
+	},
+	"interval": {
+		arity: 2, sig: "interval(a,b)", example: "interval(2.9, 3.1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] + (a[1]-a[0])/2, nil },
+	},
+	// if(c, a, b) é a se c != 0, senão b; c ? a : b compila para if
+	"if": {
+		arity: 3, sig: "if(c,a,b)", example: "if(2 > 1, 10, 20)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[0] != 0 {
+				return a[1], nil
+			}
+			return a[2], nil
+		},
+	},
+	"sqrt": {
+		arity: 1, sig: "sqrt(x)", example: "sqrt(2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[0] < 0 {
+				return 0, errors.New(msg("sqrt_negative"))
+			}
+			return math.Sqrt(a[0]), nil
+		},
+	},
+	"log": {
+		arity: 1, sig: "log(x)", example: "log(1000)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Log10(a[0]), nil },
+	},
+	"ln": {
+		arity: 1, sig: "ln(x)", example: "ln(e^2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Log(a[0]), nil },
+	},
+	"abs": {
+		arity: 1, sig: "abs(x)", example: "abs(-3.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Abs(a[0]), nil },
+	},
+	"floor": {
+		arity: 1, sig: "floor(x)", example: "floor(-2.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Floor(a[0]), nil },
+	},
+	"ceil": {
+		arity: 1, sig: "ceil(x)", example: "ceil(-2.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Ceil(a[0]), nil },
+	},
+	"round": {
+		arity: 1, sig: "round(x)", example: "round(2.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Round(a[0]), nil },
+	},
+	"max": {
+		arity: 2, sig: "max(a,b)", example: "max(3, 9)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if len(a) < 2 {
+				return 0, fmt.Errorf(msg("needs_2_args"), "max")
+			}
+			if a[0] > a[1] {
+				return a[0], nil
+			}
+			return a[1], nil
+		},
+	},
+	"min": {
+		arity: 2, sig: "min(a,b)", example: "min(4, -2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if len(a) < 2 {
+				return 0, fmt.Errorf(msg("needs_2_args"), "min")
+			}
+			if a[0] < a[1] {
+				return a[0], nil
+			}
+			return a[1], nil
+		},
+	},
+	// divmod dá dois resultados; enquanto a pilha só guarda números, cada um
+	// tem a sua função: quociente (arredondado para baixo) e resto
+	"divmod_q": {
+		arity: 2, sig: "divmod_q(a,b)", example: "divmod_q(-7, 2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[1] == 0 {
+				return 0, errors.New(msg("division_by_zero"))
+			}
+			return math.Floor(a[0] / a[1]), nil
+		},
+	},
+	"divmod_r": {
+		arity: 2, sig: "divmod_r(a,b)", example: "divmod_r(-7, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[1] == 0 {
+				return 0, errors.New(msg("division_by_zero"))
+			}
+			return a[0] - a[1]*math.Floor(a[0]/a[1]), nil
+		},
+	},
+	"copysign": {
+		arity: 2, sig: "copysign(x,y)", example: "copysign(3, -1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Copysign(a[0], a[1]), nil },
+	},
+	// resto IEEE 754
+	"remainder": {
+		arity: 2, sig: "remainder(x,y)", example: "remainder(5, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Remainder(a[0], a[1]), nil },
+	},
+	// max(x-y, 0)
+	"dim": {
+		arity: 2, sig: "dim(x,y)", example: "dim(5, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Dim(a[0], a[1]), nil },
+	},
+	// raiz cúbica real: cbrt(-8) = -2, enquanto (-8)^(1/3) dá NaN
+	"cbrt": {
+		arity: 1, sig: "cbrt(x)", example: "cbrt(-8)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return math.Cbrt(a[0]), nil },
+	},
+	"isqrt": {
+		arity: 1, sig: "isqrt(n)", example: "isqrt(17)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("isqrt", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 0 {
+				return 0, fmt.Errorf(msg("needs_nonneg_int"), "isqrt")
+			}
+			return float64(isqrt(n)), nil
+		},
+	},
+	"factorial": {
+		arity: 1, sig: "factorial(n)", example: "factorial(5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("factorial", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 0 {
+				return 0, fmt.Errorf(msg("needs_nonneg_int"), "factorial")
+			}
+			res := 1.0
+			for i := int64(2); i <= n && !math.IsInf(res, 1); i++ {
+				res *= float64(i)
+			}
+			return res, nil
+		},
+	},
+	// poly(x, a0, a1, ...) = a0 + a1·x + a2·x² + ..., pelo esquema de Horner
+	"poly": {
+		arity: -2, sig: "poly(x,a0,a1,...)", example: "poly(3, 1, 2, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return horner(a[0], a[1:]), nil },
+	},
+	// polyeval(x) avalia o polinómio definido com :poly ou polyfit
+	"polyeval": {
+		arity: 1, sig: "polyeval(x)", example: "polyeval(2)", state: true,
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			if ctx.poly == nil {
+				return 0, errors.New(msg("no_poly"))
+			}
+			return horner(a[0], ctx.poly), nil
+		},
+	},
+	"polyfit": {
+		arity: -3, sig: "polyfit(x1,y1,...,xn,yn,grau)", example: "polyfit(0, 1, 1, 3, 2, 5, 1)", state: true,
+		fn: polyFit,
+	},
+	// polyderiv(x, a0, a1, ...) é a derivada do mesmo polinómio em x
+	"polyderiv": {
+		arity: -2, sig: "polyderiv(x,a0,a1,...)", example: "polyderiv(2, 0, 0, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			x, c := a[0], a[1:]
+			res := 0.0
+			for i := len(c) - 1; i >= 1; i-- {
+				res = res*x + float64(i)*c[i]
+			}
+			return res, nil
+		},
+	},
+	// polyeval_at_roots(x, r1, r2, ...) = (x-r1)(x-r2)..., o polinómio mónico
+	// com essas raízes
+	"polyeval_at_roots": {
+		arity: -2, sig: "polyeval_at_roots(x,r1,r2,...)", example: "polyeval_at_roots(3, 1, 2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			res := 1.0
+			for _, r := range a[1:] {
+				res *= a[0] - r
+			}
+			return res, nil
+		},
+	},
+	// cf(x, n) mostra os n primeiros termos da fração contínua de x, ex.:
+	// [3; 7, 15, 1, 292] para pi, e devolve x
+	"cf": {
+		arity: 2, sig: "cf(x,n)", example: "cf(pi, 5)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			terms, err := continuedFraction(a[0], a[1])
+			if err != nil {
+				return 0, err
+			}
+			ctx.note("%s", formatCF(terms))
 			return a[0], nil
-		}
-		return a[1], nil
+		},
+	},
+	// cf_convergent(x, n) é a n-ésima convergente p/q de x (n a partir de 1)
+	"cf_convergent": {
+		arity: 2, sig: "cf_convergent(x,n)", example: "cf_convergent(pi, 4)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			terms, err := continuedFraction(a[0], a[1])
+			if err != nil {
+				return 0, err
+			}
+			p, q := 1.0, 0.0 // p(-1), q(-1)
+			pp, qq := 0.0, 1.0
+			for _, t := range terms {
+				p, pp = t*p+pp, p
+				q, qq = t*q+qq, q
+			}
+			if ctx.debug {
+				ctx.note("%.0f/%.0f", p, q)
+			}
+			return p / q, nil
+		},
+	},
+	// arredondamento a múltiplos de m: floorm(2.7, 0.5) = 2.5, ceilm(2.3, 0.25) = 2.5
+	"floorm": {
+		arity: 2, sig: "floorm(x,m)", example: "floorm(2.7, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[1] <= 0 {
+				return 0, errors.New(msg("multiple_positive"))
+			}
+			return a[1] * math.Floor(a[0]/a[1]), nil
+		},
+	},
+	"ceilm": {
+		arity: 2, sig: "ceilm(x,m)", example: "ceilm(2.3, 0.25)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[1] <= 0 {
+				return 0, errors.New(msg("multiple_positive"))
+			}
+			return a[1] * math.Ceil(a[0]/a[1]), nil
+		},
+	},
+	// polinómios de Chebyshev de primeira (T) e segunda (U) espécie
+	"chebt": {
+		arity: 2, sig: "chebT(n,x)", example: "chebT(3, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return chebyshev("chebT", a[0], a[1]) },
+	},
+	"chebu": {
+		arity: 2, sig: "chebU(n,x)", example: "chebU(3, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return chebyshev("chebU", a[0], a[1]) },
+	},
+	// solve_quadratic(a, b, c) resolve ax² + bx + c = 0: mostra as raízes
+	// reais e devolve a maior (NaN, com um aviso, se forem complexas)
+	"solve_quadratic": {
+		arity: 3, sig: "solve_quadratic(a,b,c)", example: "solve_quadratic(1, -5, 6)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) { return solveQuadratic(ctx, a[0], a[1], a[2]) },
+	},
+	// legendre(n, x) é P_n(x); legendre_roots(n) devolve a maior raiz de P_n e
+	// mostra todas, com os pesos da quadratura de Gauss-Legendre
+	"legendre": {
+		arity: 2, sig: "legendre(n,x)", example: "legendre(2, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("legendre", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n > maxLoopSteps {
+				return 0, fmt.Errorf(msg("range_too_long"), n, maxLoopSteps)
+			}
+			p, _ := legendre(int(n), a[1])
+			return p, nil
+		},
+	},
+	"legendre_roots": {
+		arity: 1, sig: "legendre_roots(n)", example: "legendre_roots(3)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("legendre_roots", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 1 || n > maxGaussPoints {
+				return 0, fmt.Errorf(msg("legendre_points"), maxGaussPoints)
+			}
+			xs, ws := gaussLegendre(int(n))
+			for i := range xs {
+				ctx.note("x%d = %.17g, w%d = %.17g", i+1, xs[i], i+1, ws[i])
+			}
+			return xs[len(xs)-1], nil
+		},
+	},
+	// lambertW(x) é o ramo principal de W, a solução de W·e^W = x
+	"lambertw": {
+		arity: 1, sig: "lambertW(x)", example: "lambertW(e)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return lambertW(a[0]) },
+	},
+	"zeta": {
+		arity: 1, sig: "zeta(s)", example: "zeta(2)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return zeta(a[0]) },
+	},
+	// fatoriais crescente e decrescente: poch(x,n) = x(x+1)...(x+n-1) e
+	// fallfact(x,n) = x(x-1)...(x-n+1) = poch(x-n+1, n)
+	"poch": {
+		arity: 2, sig: "poch(x,n)", example: "poch(3, 4)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return pochhammer(a[0], a[1]) },
+	},
+	"fallfact": {
+		arity: 2, sig: "fallfact(x,n)", example: "fallfact(10, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return pochhammer(a[0]-a[1]+1, a[1]) },
+	},
+	// matrizes por linhas: det2(a,b,c,d) é o determinante de [[a,b],[c,d]]
+	"det2": {
+		arity: 4, sig: "det2(a,b,c,d)", example: "det2(1, 2, 3, 4)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0]*a[3] - a[1]*a[2], nil },
+	},
+	// det3 por expansão em cofatores ao longo da primeira linha
+	"det3": {
+		arity: 9, sig: "det3(a,...,i)", example: "det3(2,0,0, 0,3,0, 0,0,4)",
+		fn: func(_ *EvalContext, m ...float64) (float64, error) {
+			return m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6]), nil
+		},
+	},
+	"trace2": {
+		arity: 4, sig: "trace2(a,b,c,d)", example: "trace2(1, 2, 3, 4)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] + a[3], nil },
+	},
+	"trace3": {
+		arity: 9, sig: "trace3(a,...,i)", example: "trace3(1,2,3, 4,5,6, 7,8,9)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0] + a[4] + a[8], nil },
+	},
+	// vetores: dot2(ax,ay,bx,by), cross2 dá a componente z de a×b e angle2 o
+	// ângulo com sinal de a para b, em radianos
+	"dot2": {
+		arity: 4, sig: "dot2(ax,ay,bx,by)", example: "dot2(1, 2, 3, 4)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0]*a[2] + a[1]*a[3], nil },
+	},
+	"cross2": {
+		arity: 4, sig: "cross2(ax,ay,bx,by)", example: "cross2(1, 0, 0, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0]*a[3] - a[1]*a[2], nil },
+	},
+	"angle2": {
+		arity: 4, sig: "angle2(ax,ay,bx,by)", example: "angle2(1, 0, 0, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			return math.Atan2(a[0]*a[3]-a[1]*a[2], a[0]*a[2]+a[1]*a[3]), nil
+		},
+	},
+	// rumos (0 = norte, sentido horário) e ângulos matemáticos (0 = este,
+	// sentido anti-horário), na unidade angular atual: b ↦ 90° - b nos dois
+	// sentidos, normalizado para [0, 360°)
+	"bearing_to_math": {
+		arity: 1, sig: "bearing_to_math(b)", example: "bearing_to_math(to_rad(45))",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			return ctx.normalizeAngle(ctx.fullTurn()/4 - a[0]), nil
+		},
+	},
+	"math_to_bearing": {
+		arity: 1, sig: "math_to_bearing(a)", example: "math_to_bearing(pi)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			return ctx.normalizeAngle(ctx.fullTurn()/4 - a[0]), nil
+		},
 	},
-	"min": func(a ...float64) (float64, error) {
-		if len(a) < 2 {
-			return 0, errors.New("min precisa de 2 argumentos")
+	"normalize_angle": {
+		arity: 1, sig: "normalize_angle(a)", example: "normalize_angle(-pi/2)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) { return ctx.normalizeAngle(a[0]), nil },
+	},
+	// números complexos como pares (re, im), já que os valores da calculadora
+	// são reais; os ângulos seguem :rad/:deg/:grad
+	"phase": {
+		arity: 2, sig: "phase(re,im)", example: "phase(1, 1)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			return ctx.fromRadians(math.Atan2(a[1], a[0])), nil
+		},
+	},
+	"polar": {
+		arity: 2, sig: "polar(re,im)", example: "polar(3, 4)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			r := math.Hypot(a[0], a[1])
+			ctx.note("r = %.12g, θ = %s", r, ctx.angleString(math.Atan2(a[1], a[0])))
+			return r, nil
+		},
+	},
+	// rect(r, θ) devolve a parte real e mostra o número re + im·i
+	"rect": {
+		arity: 2, sig: "rect(r,theta)", example: "rect(2, pi/3)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			t := ctx.toRadians(a[1])
+			re, im := a[0]*math.Cos(t), a[0]*math.Sin(t)
+			if im < 0 {
+				ctx.note("%.12g - %.12gi", re, -im)
+			} else {
+				ctx.note("%.12g + %.12gi", re, im)
+			}
+			return re, nil
+		},
+	},
+	"dot3": {
+		arity: 6, sig: "dot3(ax,ay,az,bx,by,bz)", example: "dot3(1, 2, 3, 4, 5, 6)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) { return a[0]*a[3] + a[1]*a[4] + a[2]*a[5], nil },
+	},
+	// cross3 devolve |a×b|, já que o resultado não pode ser um vetor
+	"cross3": {
+		arity: 6, sig: "cross3(ax,ay,az,bx,by,bz)", example: "cross3(1, 0, 0, 0, 1, 0)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			x := a[1]*a[5] - a[2]*a[4]
+			y := a[2]*a[3] - a[0]*a[5]
+			z := a[0]*a[4] - a[1]*a[3]
+			return math.Sqrt(x*x + y*y + z*z), nil
+		},
+	},
+	// distribuições: binom_pmf(k,n,p), binom_cdf(k,n,p), poisson_pmf(k,λ),
+	// normal_pdf(x,μ,σ) e normal_cdf(x,μ,σ)
+	"binom_pmf": {
+		arity: 3, sig: "binom_pmf(k,n,p)", example: "binom_pmf(3, 10, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			k, n, err := binomArgs("binom_pmf", a)
+			if err != nil {
+				return 0, err
+			}
+			return binomPMF(k, n, a[2]), nil
+		},
+	},
+	"binom_cdf": {
+		arity: 3, sig: "binom_cdf(k,n,p)", example: "binom_cdf(3, 10, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			k, n, err := binomArgs("binom_cdf", a)
+			if err != nil {
+				return 0, err
+			}
+			sum := 0.0
+			for i := int64(0); i <= min(k, n); i++ {
+				sum += binomPMF(i, n, a[2])
+			}
+			return math.Min(sum, 1), nil
+		},
+	},
+	"poisson_pmf": {
+		arity: 2, sig: "poisson_pmf(k,lambda)", example: "poisson_pmf(2, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			k, err := asInt("poisson_pmf", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if k < 0 {
+				return 0, fmt.Errorf(msg("needs_nonneg_int"), "poisson_pmf")
+			}
+			if a[1] <= 0 {
+				return 0, fmt.Errorf(msg("needs_positive"), "poisson_pmf", "lambda")
+			}
+			lg, _ := math.Lgamma(float64(k) + 1)
+			return math.Exp(float64(k)*math.Log(a[1]) - a[1] - lg), nil
+		},
+	},
+	"normal_pdf": {
+		arity: 3, sig: "normal_pdf(x,mu,sigma)", example: "normal_pdf(0, 0, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[2] <= 0 {
+				return 0, fmt.Errorf(msg("needs_positive"), "normal_pdf", "sigma")
+			}
+			z := (a[0] - a[1]) / a[2]
+			return math.Exp(-z*z/2) / (a[2] * math.Sqrt(2*math.Pi)), nil
+		},
+	},
+	"normal_cdf": {
+		arity: 3, sig: "normal_cdf(x,mu,sigma)", example: "normal_cdf(1.96, 0, 1)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[2] <= 0 {
+				return 0, fmt.Errorf(msg("needs_positive"), "normal_cdf", "sigma")
+			}
+			return 0.5 * (1 + math.Erf((a[0]-a[1])/(a[2]*math.Sqrt2))), nil
+		},
+	},
+	// inversa da função de distribuição normal: qnorm(0.975) ≈ 1.96;
+	// qnorm(p, mu, sigma) para uma normal qualquer
+	"qnorm": {
+		arity: -1, sig: "qnorm(p[,mu,sigma])", example: "qnorm(0.975)",
+		fn: qnorm,
+	},
+	"probit": {
+		arity: -1, sig: "probit(p[,mu,sigma])", example: "probit(0.975)",
+		fn: qnorm,
+	},
+	// valores críticos: chi2_ppf(p, df) e t_ppf(p, df), ex.: t_ppf(0.975, 20) ≈ 2.086
+	"chi2_ppf": {
+		arity: 2, sig: "chi2_ppf(p,df)", example: "chi2_ppf(0.95, 1)",
+		fn: chi2PPF,
+	},
+	"t_ppf": {
+		arity: 2, sig: "t_ppf(p,df)", example: "t_ppf(0.975, 20)",
+		fn: tPPF,
+	},
+	// médias móveis: o último argumento é a janela (ma) ou o fator alfa (ema);
+	// devolvem só o valor mais recente, ex.: ma(1, 2, 3, 4, 5, 3) = 4
+	"ma": {
+		arity: -2, sig: "ma(v1,...,vn,window)", example: "ma(1, 2, 3, 4, 5, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			vals := a[:len(a)-1]
+			w, err := asInt("ma", a[len(a)-1])
+			if err != nil {
+				return 0, err
+			}
+			if w < 1 || w > int64(len(vals)) {
+				return 0, fmt.Errorf(msg("ma_window"), len(vals))
+			}
+			sum := 0.0
+			for _, v := range vals[len(vals)-int(w):] {
+				sum += v
+			}
+			return sum / float64(w), nil
+		},
+	},
+	"ema": {
+		arity: -2, sig: "ema(v1,...,vn,alpha)", example: "ema(1, 2, 3, 0.5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			vals, alpha := a[:len(a)-1], a[len(a)-1]
+			if !(alpha > 0 && alpha <= 1) {
+				return 0, errors.New(msg("ema_alpha"))
+			}
+			res := vals[0]
+			for _, v := range vals[1:] {
+				res = alpha*v + (1-alpha)*res
+			}
+			return res, nil
+		},
+	},
+	// somas e produtos acumulados: mostram os valores intermédios, um por
+	// linha, e devolvem o total
+	"cumsum": {
+		arity: -1, sig: "cumsum(v1,...,vn)", example: "cumsum(1, 2, 3, 4, 5)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			return cumulative(ctx, loopFuncs["sigma"], a), nil
+		},
+	},
+	"cumprod": {
+		arity: -1, sig: "cumprod(v1,...,vn)", example: "cumprod(1, 2, 3, 4, 5)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			return cumulative(ctx, loopFuncs["pi_prod"], a), nil
+		},
+	},
+	// campos de bits: bits(4080, 11, 4) = 255 extrai os bits 11 a 4;
+	// setbits(v, hi, lo, x) substitui-os por x
+	"bits": {
+		arity: 3, sig: "bits(v,hi,lo)", example: "bits(0xFF0, 11, 4)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			v, hi, lo, err := bitsArgs("bits", a)
+			if err != nil {
+				return 0, err
+			}
+			return float64(v >> lo & bitMask(hi-lo+1)), nil
+		},
+	},
+	"setbits": {
+		arity: 4, sig: "setbits(v,hi,lo,x)", example: "setbits(0, 7, 4, 15)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			v, hi, lo, err := bitsArgs("setbits", a)
+			if err != nil {
+				return 0, err
+			}
+			x, err := asInt("setbits", a[3])
+			if err != nil {
+				return 0, err
+			}
+			mask := bitMask(hi - lo + 1)
+			if x < 0 || uint64(x) > mask {
+				return 0, fmt.Errorf(msg("bits_value"), x, hi-lo+1)
+			}
+			return float64(int64(v&^(mask<<lo) | uint64(x)<<lo)), nil
+		},
+	},
+	// primorial(n) é o produto dos primos <= n, prime_pi(n) quantos são; no
+	// modo :exact o primorial é exato
+	"primorial": {
+		arity: 1, sig: "primorial(n)", example: "primorial(10)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			primes, err := primesUpTo(ctx, "primorial", a[0])
+			if err != nil {
+				return 0, err
+			}
+			p := 1.0
+			for _, q := range primes {
+				p *= float64(q)
+			}
+			return p, nil
+		},
+	},
+	"prime_pi": {
+		arity: 1, sig: "prime_pi(n)", example: "prime_pi(100)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			primes, err := primesUpTo(ctx, "prime_pi", a[0])
+			return float64(len(primes)), err
+		},
+	},
+	// funções aritméticas a partir da fatorização de n > 0: omega conta os
+	// primos distintos, bigomega com multiplicidade
+	"omega": {
+		arity: 1, sig: "omega(n)", example: "omega(12)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("omega", a[0])
+			return float64(len(f)), err
+		},
+	},
+	// prime_factors(n) mostra a fatorização e devolve n
+	"prime_factors": {
+		arity: 1, sig: "prime_factors(n)", example: "prime_factors(360)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("prime_factors", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if len(f) > 0 {
+				ctx.note("%s", factorString(f))
+			}
+			return a[0], nil
+		},
+	},
+	// prime_factors_list(n) conta os primos distintos, como omega
+	"prime_factors_list": {
+		arity: 1, sig: "prime_factors_list(n)", example: "prime_factors_list(360)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("prime_factors_list", a[0])
+			return float64(len(f)), err
+		},
+	},
+	"bigomega": {
+		arity: 1, sig: "bigomega(n)", example: "bigomega(12)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("bigomega", a[0])
+			return float64(bigOmega(f)), err
+		},
+	},
+	"mobius": {
+		arity: 1, sig: "mobius(n)", example: "mobius(30)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("mobius", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if bigOmega(f) != len(f) {
+				return 0, nil // tem um fator primo ao quadrado
+			}
+			return parity(len(f)), nil
+		},
+	},
+	"liouville": {
+		arity: 1, sig: "liouville(n)", example: "liouville(12)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			f, err := factorArg("liouville", a[0])
+			return parity(bigOmega(f)), err
+		},
+	},
+	// dígitos (os argumentos são truncados para inteiro e o sinal ignorado):
+	// digitsum(12345) = 15, digitalroot(12345) = 6, numdigits(255, 16) = 2
+	"digitsum": {
+		arity: 1, sig: "digitsum(n)", example: "digitsum(12345)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := truncAbs("digitsum", a[0])
+			sum := int64(0)
+			for ; n > 0; n /= 10 {
+				sum += n % 10
+			}
+			return float64(sum), err
+		},
+	},
+	"digitalroot": {
+		arity: 1, sig: "digitalroot(n)", example: "digitalroot(12345)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := truncAbs("digitalroot", a[0])
+			if n == 0 {
+				return 0, err
+			}
+			return float64(1 + (n-1)%9), err
+		},
+	},
+	// digits(n, base) mostra n como soma de dígitos vezes potências da base
+	"digits": {
+		arity: 2, sig: "digits(n,base)", example: "digits(1234, 10)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("digits", a[0])
+			if err != nil {
+				return 0, err
+			}
+			base, err := asInt("digits", a[1])
+			if err != nil {
+				return 0, err
+			}
+			if base < 2 || base > 36 {
+				return 0, errors.New(msg("base_range"))
+			}
+			ctx.note("%s", powerExpansion(n, int(base)))
+			return a[0], nil
+		},
+	},
+	// to_words(n) mostra n por extenso, em inglês; o valor devolvido é 0
+	"to_words": {
+		arity: 1, sig: "to_words(n)", example: "to_words(42)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("to_words", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 0 || n > maxWords {
+				return 0, fmt.Errorf(msg("to_words_range"), int64(maxWords))
+			}
+			ctx.note("%s", toWords(n))
+			return 0, nil
+		},
+	},
+	// roman(n) mostra n em numeração romana e devolve-o; o inverso é :roman XIV
+	"roman": {
+		arity: 1, sig: "roman(n)", example: "roman(2024)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asInt("roman", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 1 || n > 3999 {
+				return 0, errors.New(msg("roman_range"))
+			}
+			ctx.note("%s", toRoman(int(n)))
+			return a[0], nil
+		},
+	},
+	"numdigits": {
+		arity: 2, sig: "numdigits(n,base)", example: "numdigits(255, 16)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := truncAbs("numdigits", a[0])
+			if err != nil {
+				return 0, err
+			}
+			base, err := asInt("numdigits", a[1])
+			if err != nil {
+				return 0, err
+			}
+			if base < 2 || base > 36 {
+				return 0, errors.New(msg("base_range"))
+			}
+			d := 1
+			for ; n >= base; n /= base {
+				d++
+			}
+			return float64(d), nil
+		},
+	},
+	// fmod(x, y) é o fmod do C (math.Mod): resto com o sinal de x, ao contrário
+	// de remainder (IEEE 754, em [-y/2, y/2]) e de divmod_r (sinal de y)
+	"fmod": {
+		arity: 2, sig: "fmod(x,y)", example: "fmod(-7, 3)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			if a[1] == 0 {
+				return 0, errors.New(msg("division_by_zero"))
+			}
+			return math.Mod(a[0], a[1]), nil
+		},
+	},
+	// sucessões inteiras (n >= 0); acima de 2^53 os valores deixam de ser exatos
+	"catalan": {
+		arity: 1, sig: "catalan(n)", example: "catalan(5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("catalan", a[0])
+			if err != nil {
+				return 0, err
+			}
+			c := 1.0 // C(k+1) = C(k)·2(2k+1)/(k+2)
+			for k := int64(0); k < n && !math.IsInf(c, 1); k++ {
+				c = c * float64(2*(2*k+1)) / float64(k+2)
+			}
+			return math.Round(c), nil
+		},
+	},
+	"bell": {
+		arity: 1, sig: "bell(n)", example: "bell(5)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("bell", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n > maxBell {
+				return math.Inf(1), nil
+			}
+			// triângulo de Bell: cada linha começa no último valor da anterior
+			row := []float64{1}
+			for i := int64(0); i < n; i++ {
+				next := []float64{row[len(row)-1]}
+				for _, v := range row {
+					next = append(next, next[len(next)-1]+v)
+				}
+				row = next
+			}
+			return row[0], nil
+		},
+	},
+	"lucas": {
+		arity: 1, sig: "lucas(n)", example: "lucas(10)",
+		fn: func(_ *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("lucas", a[0])
+			if err != nil {
+				return 0, err
+			}
+			f, f1 := fibPair(n)
+			return 2*f1 - f, nil // L(n) = F(n-1) + F(n+1)
+		},
+	},
+	// fib_pair(n) devolve F(n) e mostra também F(n+1), por duplicação rápida
+	"fib_pair": {
+		arity: 1, sig: "fib_pair(n)", example: "fib_pair(10)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("fib_pair", a[0])
+			if err != nil {
+				return 0, err
+			}
+			f, f1 := fibPair(n)
+			ctx.note("F(%d) = %.17g, F(%d) = %.17g", n, f, n+1, f1)
+			return f, nil
+		},
+	},
+	// fib(n) por duplicação rápida, O(log n); no modo :exact o resultado é exato
+	"fib": {
+		arity: 1, sig: "fib(n)", example: "fib(10)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("fib", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n > maxFib {
+				ctx.note(msg("fib_overflow"), maxFib)
+			}
+			f, _ := fibPair(n)
+			return f, nil
+		},
+	},
+	// goldenratio_approx(n) = fib(n+1)/fib(n), que converge para φ; com
+	// :verbose on mostra as razões anteriores
+	"goldenratio_approx": {
+		arity: 1, sig: "goldenratio_approx(n)", example: "goldenratio_approx(10)",
+		fn: func(ctx *EvalContext, a ...float64) (float64, error) {
+			n, err := asNonNegInt("goldenratio_approx", a[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 1 || n >= maxFib {
+				return 0, fmt.Errorf(msg("goldenratio_range"), maxFib-1)
+			}
+			if ctx.verbose {
+				phi := (1 + math.Sqrt(5)) / 2
+				for k := int64(1); k < n; k++ {
+					f, g := fibPair(k)
+					ctx.note(msg("goldenratio_step"), k+1, k, g/f, g/f-phi)
+				}
+			}
+			f, g := fibPair(n)
+			return g / f, nil
+		},
+	},
+	// sigma(expr, x, a, b) e afins: os primeiros argumentos ficam por avaliar (ver evalLazy)
+	"sigma":            {arity: 4, lazy: 2, usage: "loop_usage", sig: "sigma(expr,x,a,b)", example: "sigma(k^2, k, 1, 10)"},
+	"pi_prod":          {arity: 4, lazy: 2, usage: "loop_usage", sig: "pi_prod(expr,x,a,b)", example: "pi_prod(k, k, 1, 5)"},
+	"verify_identity":  {arity: 3, lazy: 2, usage: "usage_verify", sig: "verify_identity(f,g,n)", example: "verify_identity(sin(x)^2 + cos(x)^2, 1, 100)"},
+	"complexity":       {arity: 1, lazy: 1, usage: "usage_complexity", sig: "complexity(expr)", example: "complexity(2*(3+4))"},
+	"gradient_descent": {arity: 4, lazy: 1, usage: "usage_gd", sig: "gradient_descent(f,x0,lr,passos)", example: "gradient_descent((x-3)^2, 0, 0.25, 50)"},
+	"tabulate":         {arity: 4, lazy: 1, usage: "usage_tabulate", sig: "tabulate(f,a,b,passo)", example: "tabulate(sin, 0, pi, pi/6)"},
+	"integral_approx":  {arity: -3, lazy: 5, usage: "usage_integral", sig: "integral_approx(f,a,b,n,método)", example: "integral_approx(sin, 0, pi, 10, gauss5)"},
+	"fixed_point":      {arity: 3, lazy: 1, usage: "usage_fixed", sig: "fixed_point(g,x0,tol)", example: "fixed_point(cos(x), 1, 1e-12)"},
+	// number_format(x, casas, sep_decimal, sep_milhares) mostra x formatado e
+	// devolve-o sem alterações; os separadores são texto entre aspas, que só
+	// aqui é aceite, por isso todos os argumentos ficam por avaliar
+	"number_format": {arity: 4, lazy: 4, usage: "usage_number_format", sig: `number_format(x,casas,",",".")`, example: `number_format(1234567.891, 2, ".", ",")`},
+}
+
+// continuedFraction calcula os n primeiros termos da fração contínua de x:
+// a_k = floor(x), x = 1/(x - a_k). Dá erro se a expansão terminar antes.
+func continuedFraction(x, nf float64) ([]float64, error) {
+	n, err := asInt("cf", nf)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > 100 {
+		return nil, errors.New(msg("cf_terms"))
+	}
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return nil, errors.New(msg("non_finite"))
+	}
+	terms := make([]float64, 0, n)
+	for len(terms) < int(n) {
+		a := math.Floor(x)
+		terms = append(terms, a)
+		frac := x - a
+		if len(terms) < int(n) && frac < 1e-9 {
+			return nil, fmt.Errorf(msg("cf_ends"), len(terms), formatCF(terms))
+		}
+		x = 1 / frac
+	}
+	return terms, nil
+}
+
+// formatCF escreve os termos na notação [a0; a1, a2, ...].
+func formatCF(terms []float64) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = strconv.FormatFloat(t, 'f', 0, 64)
+	}
+	if len(parts) == 1 {
+		return "[" + parts[0] + "]"
+	}
+	return "[" + parts[0] + "; " + strings.Join(parts[1:], ", ") + "]"
+}
+
+// binomArgs valida (k, n, p) de binom_pmf e binom_cdf.
+func binomArgs(name string, a []float64) (k, n int64, err error) {
+	if k, err = asInt(name, a[0]); err != nil {
+		return 0, 0, err
+	}
+	if n, err = asInt(name, a[1]); err != nil {
+		return 0, 0, err
+	}
+	if k < 0 || n < 0 {
+		return 0, 0, fmt.Errorf(msg("needs_nonneg_int"), name)
+	}
+	if !(a[2] >= 0 && a[2] <= 1) {
+		return 0, 0, fmt.Errorf(msg("prob_range"), name)
+	}
+	return k, n, nil
+}
+
+// binomial é o coeficiente binomial C(n,k), com 0 <= k <= n.
+func binomial(n, k int64) float64 {
+	k = min(k, n-k)
+	c := 1.0
+	for i := int64(1); i <= k; i++ {
+		c = c * float64(n-k+i) / float64(i)
+	}
+	return c
+}
+
+// binomPMF é C(n,k)·p^k·(1-p)^(n-k); para n grande usa logaritmos, para que
+// nem C(n,k) nem as potências saiam do alcance do float64.
+func binomPMF(k, n int64, p float64) float64 {
+	switch {
+	case k > n:
+		return 0
+	case p == 0 || p == 1:
+		if (p == 0 && k == 0) || (p == 1 && k == n) {
+			return 1
+		}
+		return 0
+	case n <= 1000:
+		return binomial(n, k) * math.Pow(p, float64(k)) * math.Pow(1-p, float64(n-k))
+	}
+	ln, _ := math.Lgamma(float64(n) + 1)
+	lk, _ := math.Lgamma(float64(k) + 1)
+	lnk, _ := math.Lgamma(float64(n-k) + 1)
+	return math.Exp(ln - lk - lnk + float64(k)*math.Log(p) + float64(n-k)*math.Log1p(-p))
+}
+
+// qnorm usa Erfcinv da biblioteca padrão, que ao contrário de Erfinv(2p-1)
+// mantém a precisão nas caudas (p próximo de 0).
+func qnorm(_ *EvalContext, a ...float64) (float64, error) {
+	mu, sigma := 0.0, 1.0
+	switch len(a) {
+	case 1:
+	case 3:
+		mu, sigma = a[1], a[2]
+		if sigma <= 0 {
+			return 0, fmt.Errorf(msg("needs_positive"), "qnorm", "sigma")
+		}
+	default:
+		return 0, fmt.Errorf(msg("qnorm_args"), len(a))
+	}
+	p := a[0]
+	if !(p > 0 && p < 1) {
+		return 0, errors.New(msg("qnorm_p"))
+	}
+	return mu - sigma*math.Sqrt2*math.Erfcinv(2*p), nil
+}
+
+// cumulative combina os valores da esquerda para a direita, como sigma e
+// pi_prod, e regista cada resultado intermédio como "  k: valor".
+func cumulative(ctx *EvalContext, lf loopFunc, vals []float64) float64 {
+	acc := lf.empty
+	for i, v := range vals {
+		acc = lf.combine(acc, v)
+		ctx.note("  %d: %.15g", i+1, acc)
+	}
+	return acc
+}
+
+// bitsArgs valida (valor, hi, lo) de bits e setbits; o valor é lido em
+// complemento para dois.
+func bitsArgs(name string, a []float64) (v uint64, hi, lo uint, err error) {
+	n := make([]int64, 3)
+	for i := range n {
+		if n[i], err = asInt(name, a[i]); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	if n[2] < 0 || n[1] < n[2] || n[1] >= 64 {
+		return 0, 0, 0, fmt.Errorf(msg("bits_range"), name)
+	}
+	return uint64(n[0]), uint(n[1]), uint(n[2]), nil
+}
+
+// bitMask devolve uma máscara com os w bits mais baixos a 1 (1 <= w <= 64).
+func bitMask(w uint) uint64 { return ^uint64(0) >> (64 - w) }
+
+// asNonNegInt é asInt para argumentos que não podem ser negativos.
+func asNonNegInt(name string, x float64) (int64, error) {
+	n, err := asInt(name, x)
+	if err == nil && n < 0 {
+		err = fmt.Errorf(msg("needs_nonneg_int"), name)
+	}
+	return n, err
+}
+
+// a partir destes índices o resultado já não cabe num float64
+const (
+	maxBell = 218
+	maxFib  = 1476
+)
+
+// fibPair devolve F(n) e F(n+1) pela duplicação rápida:
+// F(2k) = F(k)·(2F(k+1) - F(k)) e F(2k+1) = F(k)² + F(k+1)².
+func fibPair(n int64) (float64, float64) {
+	if n > maxFib {
+		return math.Inf(1), math.Inf(1)
+	}
+	if n == 0 {
+		return 0, 1
+	}
+	a, b := fibPair(n / 2)
+	c := a * (2*b - a)
+	d := a*a + b*b
+	if n%2 == 0 {
+		return c, d
+	}
+	return d, c + d
+}
+
+// truncAbs trunca x para inteiro e devolve o seu valor absoluto.
+func truncAbs(name string, x float64) (int64, error) {
+	return asInt(name, math.Abs(math.Trunc(x)))
+}
+
+// primePower é um fator p^k da fatorização de um inteiro.
+type primePower struct{ p, k int64 }
+
+// factorize decompõe n >= 1 em fatores primos por divisão sucessiva.
+func factorize(n int64) []primePower {
+	var f []primePower
+	for p := int64(2); p <= n/p; p++ {
+		if n%p != 0 {
+			continue
+		}
+		pp := primePower{p: p}
+		for n%p == 0 {
+			n /= p
+			pp.k++
+		}
+		f = append(f, pp)
+	}
+	if n > 1 {
+		f = append(f, primePower{p: n, k: 1})
+	}
+	return f
+}
+
+// limites do crivo: acima de sieveWarn avisa, acima de maxSieve recusa
+const (
+	sieveWarn = 1_000_000
+	maxSieve  = 10_000_000
+)
+
+// sieve devolve os primos <= n pelo crivo de Eratóstenes.
+func sieve(n int) []int {
+	if n < 2 {
+		return nil
+	}
+	composite := make([]bool, n+1)
+	var primes []int
+	for p := 2; p <= n; p++ {
+		if composite[p] {
+			continue
+		}
+		primes = append(primes, p)
+		for m := p * p; m <= n; m += p {
+			composite[m] = true
+		}
+	}
+	return primes
+}
+
+// primesUpTo valida o argumento de primorial e prime_pi e corre o crivo.
+func primesUpTo(ctx *EvalContext, name string, x float64) ([]int, error) {
+	n, err := asNonNegInt(name, math.Floor(x))
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSieve {
+		return nil, fmt.Errorf(msg("sieve_too_big"), name, maxSieve)
+	}
+	if n > sieveWarn && ctx != nil {
+		ctx.note(msg("sieve_slow"), n)
+	}
+	return sieve(int(n)), nil
+}
+
+// factorArg fatoriza o argumento de uma função que exige um inteiro positivo.
+func factorArg(name string, x float64) ([]primePower, error) {
+	n, err := asInt(name, x)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf(msg("needs_pos_int"), name)
+	}
+	return factorize(n), nil
+}
+
+// factorString escreve a fatorização como 2^3 × 3^2 × 5; vazia para n = 1.
+func factorString(f []primePower) string {
+	parts := make([]string, len(f))
+	for i, pp := range f {
+		parts[i] = power(strconv.FormatInt(pp.p, 10), int(pp.k))
+	}
+	return strings.Join(parts, " × ")
+}
+
+// bigOmega conta os fatores primos com multiplicidade.
+func bigOmega(f []primePower) int {
+	n := 0
+	for _, pp := range f {
+		n += int(pp.k)
+	}
+	return n
+}
+
+// parity devolve (-1)^k.
+func parity(k int) float64 {
+	if k%2 == 1 {
+		return -1
+	}
+	return 1
+}
+
+// argCount devolve quantos argumentos a chamada t retira da pilha,
+// validando-os contra a aridade da função.
+func argCount(t token) (int, error) {
+	n := functions[t.val].arity
+	if t.lazy != nil {
+		// os argumentos por avaliar não passam pela pilha
+		switch {
+		case n < 0 && t.argc < -n:
+			return 0, errAt(t.offset, fmt.Errorf(msg("func_min_args"), t.val, -n))
+		case n < 0:
+			return t.argc - len(t.lazy), nil
+		case t.argc != n:
+			return 0, errAt(t.offset, fmt.Errorf(msg("func_arg_count"), t.val, n, t.argc))
+		}
+		return n - len(t.lazy), nil
+	}
+	switch {
+	case n < 0 && t.argc < -n:
+		return 0, errAt(t.offset, fmt.Errorf(msg("func_min_args"), t.val, -n))
+	case n < 0:
+		return t.argc, nil
+	case t.argc != 0 && t.argc != n:
+		return 0, errAt(t.offset, fmt.Errorf(msg("func_arg_count"), t.val, n, t.argc))
+	}
+	return n, nil
+}
+
+// asInt converte o argumento de uma função inteira, recusando valores com
+// parte fracionária ou fora do intervalo do int64.
+func asInt(name string, x float64) (int64, error) {
+	if x != math.Trunc(x) {
+		return 0, fmt.Errorf(msg("needs_int"), name)
+	}
+	if x < math.MinInt64 || x >= math.MaxInt64 {
+		return 0, fmt.Errorf(msg("int_range"), name)
+	}
+	return int64(x), nil
+}
+
+// chebyshev calcula T_n(x) (chebT) ou U_n(x) (chebU). Com n inteiro usa a
+// recorrência P(k+1) = 2x·P(k) - P(k-1), que vale para qualquer x; com n real
+// só há a forma trigonométrica, T_n(x) = cos(n·acos x) e
+// U_n(x) = sin((n+1)·acos x)/sin(acos x), para |x| <= 1.
+func chebyshev(name string, n, x float64) (float64, error) {
+	if n != math.Trunc(n) {
+		if math.Abs(x) > 1 {
+			return 0, fmt.Errorf(msg("cheb_domain"), name)
+		}
+		t := math.Acos(x)
+		if name == "chebT" {
+			return math.Cos(n * t), nil
+		}
+		if math.Sin(t) == 0 {
+			// limite em x = ±1: U_n(1) = n+1
+			return (n + 1) * math.Cos(n*t), nil
+		}
+		return math.Sin((n+1)*t) / math.Sin(t), nil
+	}
+	sign := 1.0
+	if n < 0 {
+		// T_-n = T_n; U_-n = -U_(n-2), e U_-1 = 0
+		if name == "chebT" {
+			n = -n
+		} else {
+			n, sign = -n-2, -1
+			if n < 0 {
+				return 0, nil
+			}
+		}
+	}
+	if n > maxLoopSteps {
+		return 0, fmt.Errorf(msg("range_too_long"), int64(n), maxLoopSteps)
+	}
+	prev, cur := 1.0, x // P(0), P(1)
+	if name == "chebU" {
+		cur = 2 * x
+	}
+	if n == 0 {
+		return sign * prev, nil
+	}
+	for k := 1; k < int(n); k++ {
+		prev, cur = cur, 2*x*cur-prev
+	}
+	return sign * cur, nil
+}
+
+// solveQuadratic usa a forma estável q = -(b + sign(b)·√Δ)/2, com as raízes
+// q/a e c/q, que evita subtrair números próximos quando b² ≫ 4ac.
+func solveQuadratic(ctx *EvalContext, a, b, c float64) (float64, error) {
+	if a == 0 {
+		if b == 0 {
+			return 0, errors.New(msg("quadratic_degenerate"))
+		}
+		ctx.note("x = %.17g", -c/b)
+		return -c / b, nil
+	}
+	d := b*b - 4*a*c
+	if d < 0 {
+		re, im := -b/(2*a), math.Sqrt(-d)/(2*math.Abs(a))
+		ctx.note(msg("quadratic_complex"), re, im)
+		return math.NaN(), nil
+	}
+	q := -(b + math.Copysign(math.Sqrt(d), b)) / 2
+	r1, r2 := q/a, 0.0
+	if q != 0 {
+		r2 = c / q
+	}
+	r1, r2 = min(r1, r2), max(r1, r2)
+	if d == 0 {
+		ctx.note("x = %.17g", r2)
+	} else {
+		ctx.note("x1 = %.17g, x2 = %.17g", r1, r2)
+	}
+	return r2, nil
+}
+
+// legendre devolve P_n(x) e P_(n-1)(x) pela recorrência de Bonnet,
+// (k+1)·P(k+1) = (2k+1)·x·P(k) - k·P(k-1).
+func legendre(n int, x float64) (p, prev float64) {
+	p, prev = 1, 0 // P(0), P(-1)
+	for k := 0; k < n; k++ {
+		p, prev = (float64(2*k+1)*x*p-float64(k)*prev)/float64(k+1), p
+	}
+	return p, prev
+}
+
+// maxGaussPoints é o maior n aceite por legendre_roots.
+const maxGaussPoints = 100
+
+// gaussLegendre calcula as raízes de P_n, por ordem crescente, e os pesos
+// w = 2/((1-x²)·P_n'(x)²). Cada raiz sai do método de Newton a partir da
+// aproximação cos(π(i-1/4)/(n+1/2)); as raízes são simétricas, por isso só
+// se calcula metade.
+func gaussLegendre(n int) (xs, ws []float64) {
+	xs, ws = make([]float64, n), make([]float64, n)
+	for i := 0; i < (n+1)/2; i++ {
+		x := math.Cos(math.Pi * (float64(i) + 0.75) / (float64(n) + 0.5))
+		var dp float64
+		for iter := 0; iter < 100; iter++ {
+			p, prev := legendre(n, x)
+			dp = float64(n) * (x*p - prev) / (x*x - 1)
+			dx := p / dp
+			x -= dx
+			if math.Abs(dx) < 1e-16 {
+				break
+			}
+		}
+		p, prev := legendre(n, x)
+		dp = float64(n) * (x*p - prev) / (x*x - 1)
+		w := 2 / ((1 - x*x) * dp * dp)
+		xs[i], xs[n-1-i] = -x, x
+		ws[i], ws[n-1-i] = w, w
+	}
+	return xs, ws
+}
+
+// lambertW resolve w·e^w = x no ramo principal (w >= -1) pelo método de
+// Halley, a partir de uma estimativa inicial: a série no ponto de ramificação
+// x = -1/e, ln(1+x) perto de zero e ln x - ln ln x para x grande.
+func lambertW(x float64) (float64, error) {
+	const branch = -1 / math.E
+	switch {
+	case math.IsNaN(x) || x < branch-1e-15:
+		return 0, fmt.Errorf(msg("lambertw_domain"), branch)
+	case x <= branch:
+		return -1, nil // -1/e arredondado pode ficar um pouco abaixo
+	case x == 0 || math.IsInf(x, 1):
+		return x, nil
+	}
+	var w float64
+	switch {
+	case x < -0.25:
+		p := math.Sqrt(2 * (math.E*x + 1))
+		w = -1 + p - p*p/3 + 11.0/72*p*p*p
+	case x < 3:
+		w = math.Log1p(x)
+	default:
+		l := math.Log(x)
+		w = l - math.Log(l)
+	}
+	for i := 0; i < 20; i++ {
+		ew := math.Exp(w)
+		f := w*ew - x
+		if w == -1 || f == 0 {
+			break
+		}
+		// Halley: w -= f / (e^w(w+1) - (w+2)f / (2w+2))
+		dw := f / (ew*(w+1) - (w+2)*f/(2*w+2))
+		w -= dw
+		if math.Abs(dw) <= 1e-15*(1+math.Abs(w)) {
+			break
+		}
+	}
+	return w, nil
+}
+
+// pochhammer calcula o fatorial crescente x(x+1)...(x+n-1). Com n inteiro
+// multiplica os fatores (para n < 0, poch(x,n) = 1/poch(x+n,-n)); com n real
+// usa Γ(x+n)/Γ(x), pelos logaritmos para não transbordar a meio.
+func pochhammer(x, n float64) (float64, error) {
+	if n != math.Trunc(n) {
+		lx, sx := math.Lgamma(x)
+		lxn, sxn := math.Lgamma(x + n)
+		return float64(sx*sxn) * math.Exp(lxn-lx), nil
+	}
+	if math.Abs(n) > maxLoopSteps {
+		return 0, fmt.Errorf(msg("range_too_long"), int64(n), maxLoopSteps)
+	}
+	if n < 0 {
+		p, err := pochhammer(x+n, -n)
+		if err != nil {
+			return 0, err
+		}
+		if p == 0 {
+			return 0, errors.New(msg("division_by_zero"))
+		}
+		return 1 / p, nil
+	}
+	p := 1.0
+	for k := 0.0; k < n && p != 0 && !math.IsInf(p, 0); k++ {
+		p *= x + k
+	}
+	if p == 0 {
+		return 0, nil // sem -0 quando um dos fatores é zero
+	}
+	return p, nil
+}
+
+// zetaTerms é o número de termos da série acelerada de zeta; o erro relativo
+// fica abaixo de 3/(3+√8)^n.
+const zetaTerms = 50
+
+// zeta calcula a função zeta de Riemann para s real. Para s >= 0 usa a
+// função eta alternada, η(s) = (1 - 2^(1-s))·ζ(s), somada com a aceleração
+// de Borwein; para s < 0, a equação funcional
+// ζ(s) = 2^s·π^(s-1)·sin(πs/2)·Γ(1-s)·ζ(1-s).
+func zeta(s float64) (float64, error) {
+	switch {
+	case s == 1:
+		return 0, errors.New(msg("zeta_pole"))
+	case math.IsInf(s, 1):
+		return 1, nil
+	case s < 0:
+		if s/2 == math.Trunc(s/2) {
+			return 0, nil // zeros triviais
+		}
+		z, err := zeta(1 - s)
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(2, s) * math.Pow(math.Pi, s-1) * math.Sin(math.Pi*s/2) * math.Gamma(1-s) * z, nil
+	}
+	// d_k = n·Σ_{i<=k} (n+i-1)!·4^i / ((n-i)!·(2i)!), somado termo a termo
+	n := zetaTerms
+	d := make([]float64, n+1)
+	term, sum := 1.0, 0.0
+	for i := 0; i <= n; i++ {
+		sum += term
+		d[i] = sum
+		term *= 4 * float64(n+i) * float64(n-i) / float64((2*i+1)*(2*i+2))
+	}
+	eta := 0.0
+	for k := n - 1; k >= 0; k-- {
+		t := (d[k] - d[n]) / math.Pow(float64(k+1), s)
+		if k%2 == 1 {
+			t = -t
+		}
+		eta += t
+	}
+	eta /= -d[n]
+	return eta / (1 - math.Pow(2, 1-s)), nil
+}
+
+// isqrt calcula floor(sqrt(n)) só com inteiros (método de Newton), sem os
+// erros de arredondamento de math.Sqrt para n grandes.
+func isqrt(n int64) int64 {
+	if n < 2 {
+		return n
+	}
+	x := n
+	y := x/2 + x%2 // (x+1)/2 sem overflow
+	for y < x {
+		x = y
+		y = (x + n/x) / 2
+	}
+	return x
+}
+
+// constantes com nome
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// dígitos em expoente: x² equivale a x^2
+var superscripts = map[rune]byte{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+	'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+}
+
+// numberFormatCall trata number_format(x, casas, sep_decimal, sep_milhares).
+// O separador decimal não pode ser vazio nem igual ao de milhares, senão o
+// número não se lê: number_format(1234.5, 2, "", "") daria "123450".
+func numberFormatCall(t token, ctx *EvalContext) (float64, error) {
+	var a [2]float64
+	for i := range a {
+		v, err := evalRPN(t.lazy[i], ctx)
+		if err != nil {
+			return 0, err
+		}
+		a[i] = v
+	}
+	var seps [2]string
+	for i, l := range t.lazy[2:] {
+		if len(l) != 1 || l[0].typ != tString {
+			return 0, errAt(t.offset, fmt.Errorf(msg("usage_number_format"), t.val))
+		}
+		seps[i] = l[0].val
+	}
+	decimals, err := asInt(t.val, a[1])
+	if err != nil {
+		return 0, err
+	}
+	if decimals < 0 || decimals > 20 {
+		return 0, errors.New(msg("number_format_decimals"))
+	}
+	if seps[0] == "" || seps[0] == seps[1] {
+		return 0, errors.New(msg("number_format_separators"))
+	}
+	ctx.note("%s", numberFormat(a[0], int(decimals), seps[0], seps[1]))
+	return a[0], nil
+}
+
+// numberFormat escreve x com o número de casas dado, o separador decimal dec
+// e o separador de milhares thou ("" para não agrupar).
+func numberFormat(x float64, decimals int, dec, thou string) string {
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	ip, fp, _ := strings.Cut(strconv.FormatFloat(math.Abs(x), 'f', decimals, 64), ".")
+	var b strings.Builder
+	if x < 0 && strings.Trim(ip+fp, "0") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range ip {
+		if i > 0 && (len(ip)-i)%3 == 0 {
+			b.WriteString(thou)
+		}
+		b.WriteRune(d)
+	}
+	if fp != "" {
+		b.WriteString(dec)
+		b.WriteString(fp)
+	}
+	return b.String()
+}
+
+// superscript escreve um inteiro não negativo em expoente: 12 → ¹².
+func superscript(k int) string {
+	const sup = "⁰¹²³⁴⁵⁶⁷⁸⁹"
+	var b strings.Builder
+	for _, c := range strconv.Itoa(k) {
+		b.WriteString(string([]rune(sup)[c-'0']))
+	}
+	return b.String()
+}
+
+// powerExpansion escreve n na base dada como soma de potências:
+// 1234 → 1×10³ + 2×10² + 3×10¹ + 4×10⁰; os dígitos acima de 9 são letras.
+func powerExpansion(n int64, base int) string {
+	u := uint64(n)
+	if n < 0 {
+		u = -u // também serve para -2^63
+	}
+	ds := strconv.FormatUint(u, base)
+	terms := make([]string, len(ds))
+	for i, d := range ds {
+		terms[i] = fmt.Sprintf("%c×%d%s", d, base, superscript(len(ds)-1-i))
+	}
+	out := strings.Join(terms, " + ")
+	if n < 0 {
+		return "-(" + out + ")"
+	}
+	return out
+}
+
+// Palavras de toWords, em inglês.
+var (
+	smallWords = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+		"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	tensWords  = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	scaleWords = []string{"", "thousand", "million", "billion"}
+)
+
+// maxWords é o maior número que toWords escreve por extenso.
+const maxWords = 999_999_999_999
+
+// toWords escreve n (0 <= n <= maxWords) por extenso, em inglês: 42 →
+// forty-two, 1000000 → one million. Cada grupo de três algarismos é escrito
+// como centenas e dezenas, seguido da escala.
+func toWords(n int64) string {
+	if n == 0 {
+		return smallWords[0]
+	}
+	below1000 := func(n int64) string {
+		var parts []string
+		if n >= 100 {
+			parts = append(parts, smallWords[n/100]+" hundred")
+			n %= 100
+		}
+		switch {
+		case n >= 20 && n%10 != 0:
+			parts = append(parts, tensWords[n/10]+"-"+smallWords[n%10])
+		case n >= 20:
+			parts = append(parts, tensWords[n/10])
+		case n > 0:
+			parts = append(parts, smallWords[n])
+		}
+		return strings.Join(parts, " ")
+	}
+	var groups []string
+	for i := 0; n > 0; i++ {
+		if g := n % 1000; g != 0 {
+			w := below1000(g)
+			if scaleWords[i] != "" {
+				w += " " + scaleWords[i]
+			}
+			groups = append([]string{w}, groups...)
+		}
+		n /= 1000
+	}
+	return strings.Join(groups, " ")
+}
+
+// romanNumerals são os valores dos numerais romanos, com as formas
+// subtrativas (CM, CD, XC, XL, IX, IV), do maior para o menor.
+var romanNumerals = []struct {
+	value  int
+	symbol string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+// toRoman escreve n (1 a 3999) em numeração romana.
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for ; n >= r.value; n -= r.value {
+			b.WriteString(r.symbol)
+		}
+	}
+	return b.String()
+}
+
+// fromRoman lê um numeral romano. Só aceita a forma canónica, a que toRoman
+// escreveria: IIII, IC ou VX são erros.
+func fromRoman(s string) (int, error) {
+	up := strings.ToUpper(s)
+	n, rest := 0, up
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(rest, r.symbol) {
+			n += r.value
+			rest = rest[len(r.symbol):]
+		}
+	}
+	if rest != "" || n == 0 || n > 3999 || toRoman(n) != up {
+		return 0, fmt.Errorf(msg("roman_invalid"), s)
+	}
+	return n, nil
+}
+
+func isASCIIDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(r rune) bool { return unicode.IsLetter(r) || r == '_' }
+func isIdent(r rune) bool      { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+
+// commaLocales são as línguas em que a vírgula é o separador decimal.
+var commaLocales = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true, "nl": true,
+	"ru": true, "pl": true, "tr": true, "da": true, "sv": true, "fi": true,
+}
+
+// localeUsesComma interpreta valores como "de" ou "de_DE.UTF-8" (CALC_LOCALE).
+func localeUsesComma(locale string) bool {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "_")
+	lang, _, _ = strings.Cut(lang, ".")
+	return commaLocales[lang]
+}
+
+// scanCommaNumber lê um número a partir de s[i] com vírgula decimal e "." ou
+// espaço como separador de milhares (seguido de três dígitos), devolvendo-o
+// já na forma aceite por strconv.ParseFloat.
+func scanCommaNumber(s string, i int) (string, int) {
+	isDigit := func(j int) bool { return j < len(s) && s[j] >= '0' && s[j] <= '9' }
+	group := func(j int) bool { return isDigit(j+1) && isDigit(j+2) && isDigit(j+3) && !isDigit(j+4) }
+	var b strings.Builder
+	hasDec := false
+	j := i
+	for j < len(s) {
+		switch c := s[j]; {
+		case c >= '0' && c <= '9':
+			b.WriteByte(c)
+		case (c == '.' || c == ' ') && !hasDec && j > i && group(j):
+			// separador de milhares
+		case c == '_' && j > i && isDigit(j-1) && isDigit(j+1):
+			// separador de dígitos, como em 1_000
+		case c == ',' && !hasDec && isDigit(j+1):
+			hasDec = true
+			b.WriteByte('.')
+		case (c == 'e' || c == 'E') && j > i && (isDigit(j+1) || ((j+1 < len(s) && (s[j+1] == '+' || s[j+1] == '-')) && isDigit(j+2))):
+			b.WriteByte(c)
+			if s[j+1] == '+' || s[j+1] == '-' {
+				j++
+				b.WriteByte(s[j])
+			}
+			for isDigit(j + 1) {
+				j++
+				b.WriteByte(s[j])
+			}
+		default:
+			return b.String(), j
+		}
+		j++
+	}
+	return b.String(), j
+}
+
+// isHexDigit diz se c é um dígito hexadecimal (0-9, a-f, A-F).
+func isHexDigit(c byte) bool {
+	return isASCIIDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f')
+}
+
+// scanHexNumber lê um literal hexadecimal 0xFF0 a partir de s[i] (que é o
+// "0" de "0x") e devolve-o em decimal, já na forma aceite por
+// strconv.ParseFloat e big.Rat. Aceita _ entre dois dígitos, como em Go.
+func scanHexNumber(s string, i int) (string, int, error) {
+	var b strings.Builder
+	j := i + 2
+	for j < len(s) {
+		if s[j] == '_' {
+			if !isHexDigit(s[j-1]) || j+1 >= len(s) || !isHexDigit(s[j+1]) {
+				return "", j, errAt(j, errors.New(msg("bad_underscore")))
+			}
+		} else if isHexDigit(s[j]) {
+			b.WriteByte(s[j])
+		} else {
+			break
+		}
+		j++
+	}
+	n, _ := new(big.Int).SetString(b.String(), 16)
+	return n.String(), j, nil
+}
+
+// tokenize divide a expressão em tokens. ctx pode ser nil; quando o contexto
+// usa vírgula decimal, os argumentos das funções separam-se com ";".
+func tokenize(input string, ctx *EvalContext) ([]token, error) {
+	decimalComma := ctx != nil && ctx.decimalComma
+	var toks []token
+	s := input
+	i := 0
+	prevType := tOp // como se começasse com operador
+	absDepth := 0   // grupos |...| abertos
+	for i < len(s) {
+		ch, size := utf8.DecodeRuneInString(s[i:])
+		switch ch {
+		case '×':
+			ch = '*'
+		case '÷':
+			ch = '/'
+		}
+		if unicode.IsSpace(ch) {
+			i += size
+			continue
+		}
+		if _, ok := superscripts[ch]; ok {
+			if prevType != tNumber && prevType != tIdent && prevType != tRParen {
+				return nil, errAt(i, fmt.Errorf(msg("superscript_base"), ch))
+			}
+			start := i
+			var digits []byte
+			for i < len(s) {
+				r, n := utf8.DecodeRuneInString(s[i:])
+				d, ok := superscripts[r]
+				if !ok {
+					break
+				}
+				digits = append(digits, d)
+				i += n
+			}
+			toks = append(toks, token{typ: tOp, val: "^", offset: start}, token{typ: tNumber, val: string(digits), offset: start})
+			prevType = tNumber
+			continue
+		}
+		if ch == '0' && i+2 < len(s) && s[i+1]|0x20 == 'x' && isHexDigit(s[i+2]) {
+			num, j, err := scanHexNumber(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{typ: tNumber, val: num, offset: i})
+			prevType = tNumber
+			i = j
+			continue
+		}
+		if decimalComma && unicode.IsDigit(ch) {
+			num, j := scanCommaNumber(s, i)
+			toks = append(toks, token{typ: tNumber, val: num, offset: i})
+			prevType = tNumber
+			i = j
+			continue
+		}
+		if unicode.IsDigit(ch) || ch == '.' {
+			j := i + 1
+			hasE := false
+			for j < len(s) {
+				r := rune(s[j])
+				if unicode.IsDigit(r) || r == '.' {
+					j++
+				} else if r == '_' {
+					// separador de dígitos, como em Go: 1_000_000, só entre dois dígitos
+					if !isASCIIDigit(s[j-1]) || j+1 >= len(s) || !isASCIIDigit(s[j+1]) {
+						return nil, errAt(j, errors.New(msg("bad_underscore")))
+					}
+					j++
+				} else if (r == 'e' || r == 'E') && !hasE {
+					hasE = true
+					j++
+					if j < len(s) && (s[j] == '+' || s[j] == '-') {
+						j++
+					}
+				} else {
+					break
+				}
+			}
+			toks = append(toks, token{typ: tNumber, val: strings.ReplaceAll(s[i:j], "_", ""), offset: i})
+			prevType = tNumber
+			i = j
+			continue
+		}
+		switch ch {
+		case '+', '-', '±':
+			op := string(ch)
+			if prevType == tOp || prevType == tLParen || prevType == tComma || len(toks) == 0 {
+				op = "u" + op
+			}
+			toks = append(toks, token{typ: tOp, val: op, offset: i})
+			prevType = tOp
+			i += size
+		case '*', '/', '^':
+			if ch == '/' && i+size < len(s) && s[i+size] == '/' {
+				toks = append(toks, token{typ: tOp, val: "//", offset: i})
+				prevType = tOp
+				i += size + 1
+				continue
+			}
+			toks = append(toks, token{typ: tOp, val: string(ch), offset: i})
+			prevType = tOp
+			i += size
+		case '<', '>', '=', '!':
+			op := string(ch)
+			if i+1 < len(s) && s[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, errAt(i, errorFormatter(ctx).TokenizeError(i, ch))
+			}
+			toks = append(toks, token{typ: tOp, val: op, offset: i})
+			prevType = tOp
+			i += len(op)
+		case '"', '\'':
+			// texto entre aspas, como os separadores de number_format; o
+			// shunting-yard rejeita-o em qualquer outro sítio
+			end := strings.IndexRune(s[i+size:], ch)
+			if end < 0 {
+				return nil, errAt(i, errors.New(msg("char_unclosed")))
+			}
+			toks = append(toks, token{typ: tString, val: s[i+size : i+size+end], offset: i})
+			prevType = tNumber
+			i += size + end + size
+		case '%':
+			// 15% é 15/100; só depois de um número ou de um ")", e o que vem a
+			// seguir é como depois de um operando
+			if prevType != tNumber && prevType != tRParen {
+				return nil, errAt(i, errors.New(msg("percent_position")))
+			}
+			toks = append(toks, token{typ: tOp, val: "%", offset: i})
+			prevType = tRParen
+			i += size
+		case '?', ':':
+			typ := tQuestion
+			if ch == ':' {
+				typ = tColon
+			}
+			toks = append(toks, token{typ: typ, val: string(ch), offset: i})
+			prevType = tOp // depois de ? e de : vem um operando, como depois de um operador
+			i += size
+		case '(':
+			toks = append(toks, token{typ: tLParen, val: "(", offset: i})
+			prevType = tLParen
+			i += size
+		case ')':
+			toks = append(toks, token{typ: tRParen, val: ")", offset: i})
+			prevType = tRParen
+			i += size
+		case '|':
+			// como no menos unário, o contexto decide: depois de um operando fecha
+			// um |...| aberto ou é o ou bit a bit; caso contrário abre um |...|
+			operand := prevType == tNumber || prevType == tIdent || prevType == tRParen
+			if operand && absDepth > 0 {
+				toks = append(toks, token{typ: tRParen, val: ")", offset: i})
+				prevType = tRParen
+				absDepth--
+			} else if operand {
+				toks = append(toks, token{typ: tOp, val: "|", offset: i})
+				prevType = tOp
+			} else {
+				toks = append(toks, token{typ: tFunc, val: "abs", offset: i}, token{typ: tLParen, val: "(", offset: i})
+				prevType = tLParen
+				absDepth++
+			}
+			i += size
+		case '[':
+			// "5 [m]" no modo :units: a unidade fica ligada ao número como
+			// (5 * [m]), para que 10 [m] / 2 [s] seja (10 m)/(2 s)
+			if ctx == nil || !ctx.units {
+				return nil, errAt(i, errorFormatter(ctx).TokenizeError(i, ch))
+			}
+			if prevType != tNumber {
+				return nil, errAt(i, errors.New(msg("unit_after_number")))
+			}
+			unit, j, err := scanUnit(s, i)
+			if err != nil {
+				return nil, err
+			}
+			num := toks[len(toks)-1]
+			toks = append(toks[:len(toks)-1],
+				token{typ: tLParen, val: "(", offset: num.offset}, num,
+				token{typ: tOp, val: "*", offset: i}, token{typ: tIdent, val: "[" + unit + "]", offset: i},
+				token{typ: tRParen, val: ")", offset: i})
+			prevType = tRParen
+			i = j
+		case ',', ';':
+			if decimalComma == (ch == ',') {
+				if decimalComma {
+					return nil, errAt(i, errors.New(msg("comma_separator")))
+				}
+				return nil, errAt(i, errorFormatter(ctx).TokenizeError(i, ch))
+			}
+			toks = append(toks, token{typ: tComma, val: ",", offset: i})
+			prevType = tComma
+			i += size
+		default:
+			if isIdentStart(ch) {
+				j := i + size
+				for j < len(s) {
+					r, n := utf8.DecodeRuneInString(s[j:])
+					if !isIdent(r) {
+						break
+					}
+					j += n
+				}
+				id := s[i:j]
+				low := strings.ToLower(id)
+				operand := prevType == tNumber || prevType == tIdent || prevType == tRParen
+				if low == "of" && operand {
+					// "of" só é operador depois de um operando: 15% of 200
+					toks = append(toks, token{typ: tOp, val: "of", offset: i})
+					prevType = tOp
+					i = j
+					continue
+				}
+				if _, ok := functions[low]; ok {
+					toks = append(toks, token{typ: tFunc, val: low, offset: i})
+				} else {
+					// constantes, ans e variáveis resolvem-se em evalRPN
+					toks = append(toks, token{typ: tIdent, val: low, offset: i})
+				}
+				prevType = tIdent
+				i = j
+			} else {
+				return nil, errAt(i, errorFormatter(ctx).TokenizeError(i, ch))
+			}
+		}
+	}
+	return toks, nil
+}
+
+func shuntingYard(toks []token, ctx *EvalContext) ([]token, error) {
+	var output []token
+	var stack []token
+	var argCounts []int // um por "(" aberto: nº de argumentos, ou -1 se não é uma chamada
+	var argStarts []int // um por "(" aberto: posição em output do argumento atual
+	// popOp passa o topo da pilha para a saída; um : pendente fecha o
+	// condicional, que fica como if(c, a, b), e um ? sem : é um erro
+	popOp := func() error {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch top.typ {
+		case tQuestion:
+			return errAt(top.offset, errorFormatter(ctx).ParseError("?", ":"))
+		case tColon:
+			top = token{typ: tFunc, val: "if", offset: top.offset, argc: 3, cond: true}
+		}
+		output = append(output, top)
+		return nil
+	}
+	for i, t := range toks {
+		switch t.typ {
+		case tNumber, tIdent, tString:
+			output = append(output, t)
+		case tFunc:
+			stack = append(stack, t)
+		case tComma:
+			for len(stack) > 0 && stack[len(stack)-1].typ != tLParen {
+				if err := popOp(); err != nil {
+					return nil, err
+				}
+			}
+			if len(stack) == 0 {
+				return nil, errAt(t.offset, errorFormatter(ctx).ParseError(",", "("))
+			}
+			if len(stack) > 1 && argCounts[len(argCounts)-1] <= functions[stack[len(stack)-2].val].lazy {
+				// sigma(expr, x, ...): os primeiros argumentos ficam no token
+				f := &stack[len(stack)-2]
+				start := argStarts[len(argStarts)-1]
+				f.lazy = append(f.lazy, append([]token(nil), output[start:]...))
+				output = output[:start]
+			}
+			argCounts[len(argCounts)-1]++
+			argStarts[len(argStarts)-1] = len(output)
+		case tOp:
+			if ops[t.val].postfix {
+				// o operando já está inteiro na saída
+				output = append(output, t)
+				continue
+			}
+			// um operador prefixo não tem operando à esquerda: não desempilha
+			// nada, para que 2^-1 seja 2^(-1)
+			for len(stack) > 0 && stack[len(stack)-1].typ == tOp && !ops[t.val].unary {
+				top := stack[len(stack)-1].val
+				curr := t.val
+				if (ops[top].prec > ops[curr].prec) ||
+					(ops[top].prec == ops[curr].prec && !ops[curr].rightAssoc) {
+					output = append(output, stack[len(stack)-1])
+					stack = stack[:len(stack)-1]
+				} else {
+					break
+				}
+			}
+			stack = append(stack, t)
+		case tQuestion:
+			// o condicional liga menos do que qualquer operador, e é associativo
+			// à direita: a ? b : c ? d : e é a ? b : (c ? d : e)
+			for len(stack) > 0 && stack[len(stack)-1].typ == tOp {
+				if err := popOp(); err != nil {
+					return nil, err
+				}
+			}
+			stack = append(stack, t)
+		case tColon:
+			for len(stack) > 0 && stack[len(stack)-1].typ != tQuestion && stack[len(stack)-1].typ != tLParen {
+				if err := popOp(); err != nil {
+					return nil, err
+				}
+			}
+			if len(stack) == 0 || stack[len(stack)-1].typ != tQuestion {
+				return nil, errAt(t.offset, errorFormatter(ctx).ParseError(":", "?"))
+			}
+			stack[len(stack)-1] = t
+		case tLParen:
+			stack = append(stack, t)
+			if i > 0 && toks[i-1].typ == tFunc {
+				argCounts = append(argCounts, 1)
+			} else {
+				argCounts = append(argCounts, -1)
+			}
+			argStarts = append(argStarts, len(output))
+		case tRParen:
+			for len(stack) > 0 && stack[len(stack)-1].typ != tLParen {
+				if err := popOp(); err != nil {
+					return nil, err
+				}
+			}
+			if len(stack) == 0 {
+				return nil, errAt(t.offset, errorFormatter(ctx).ParseError(")", "("))
+			}
+			stack = stack[:len(stack)-1]
+			argc, start := argCounts[len(argCounts)-1], argStarts[len(argStarts)-1]
+			argCounts = argCounts[:len(argCounts)-1]
+			argStarts = argStarts[:len(argStarts)-1]
+			if toks[i-1].typ == tLParen {
+				argc = 0 // f()
+			}
+			if len(stack) > 0 && stack[len(stack)-1].typ == tFunc {
+				f := stack[len(stack)-1]
+				if argc > 0 && argc <= functions[f.val].lazy {
+					// complexity(expr): também o último argumento fica no token
+					f.lazy = append(f.lazy, append([]token(nil), output[start:]...))
+					output = output[:start]
+				}
+				f.argc = argc
+				output = append(output, f)
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	for len(stack) > 0 {
+		if top := stack[len(stack)-1]; top.typ == tLParen {
+			return nil, errAt(top.offset, errorFormatter(ctx).ParseError("(", ")"))
+		}
+		if err := popOp(); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkStrings(output); err != nil {
+		return nil, err
+	}
+	for _, t := range output {
+		if t.typ == tFunc {
+			d, ok := functions[t.val]
+			if !ok {
+				return nil, errAt(t.offset, fmt.Errorf(msg("unsupported_func"), t.val))
+			}
+			// nas variádicas os argumentos opcionais podem faltar
+			if len(t.lazy) != d.lazy && (d.arity >= 0 || len(t.lazy) != t.argc) {
+				return nil, errAt(t.offset, fmt.Errorf(msg(d.usage), t.val))
+			}
+		}
+	}
+	return output, nil
+}
+
+// checkStrings rejeita o texto entre aspas fora dos separadores de
+// number_format, onde tem de ser o argumento inteiro: "a"+1 é um erro.
+func checkStrings(rpn []token) error {
+	for _, t := range rpn {
+		if t.typ == tString {
+			return errAt(t.offset, errors.New(msg("string_position")))
+		}
+		for i, l := range t.lazy {
+			if t.val == "number_format" && i >= 2 && len(l) == 1 && l[0].typ == tString {
+				continue
+			}
+			if err := checkStrings(l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EvalContext guarda o estado que influencia a avaliação de uma expressão.
+type EvalContext struct {
+	lastAns      float64
+	vars         map[string]float64 // variáveis do utilizador
+	intMode      bool               // "/" passa a ser divisão inteira
+	debug        bool               // :debug, mostra detalhes das avaliações
+	decimalComma bool               // "3,14" é um número e ";" separa argumentos
+	exact        bool               // modo :exact: inteiros com big.Int
+	exactAns     *big.Rat           // ans exato, quando o último resultado o foi
+	interval     bool               // modo :interval: aritmética de intervalos
+	intervalAns  *Interval          // ans como intervalo, no modo :interval
+	units        bool               // modo :units: "5 [m]" e análise dimensional
+	unitAns      *UnitVector        // unidade de ans, no modo :units
+	varResults   map[string]result  // valor exato, intervalo ou unidade das variáveis que os têm, como exactAns, intervalAns e unitAns para ans
+	errors       ErrorFormatter     // mensagens de erro próprias (nil: as do catálogo)
+	watch        *watcher           // expressão de :watch; nil quando desligado
+	cache        ExprCache          // nil quando a cache está desligada
+	notes        []string           // avisos gerados durante a última avaliação
+	poly         []float64          // coeficientes de :poly e polyfit, por grau crescente
+	angleUnit    string             // unidade dos ângulos de sin, cos e tan: "rad" (ou ""), "deg" ou "grad"
+	profile      Profile            // chamadas contadas por :profile; nil quando desligado
+	verbose      bool               // :verbose, mostra cada passo de evalRPN
+	depth        int                // chamadas de evalRPN em curso (sigma, ...), para indentar :verbose
+	stackDepth   bool               // :stackdepth, mede a profundidade máxima da pilha
+	maxDepth     int                // profundidade máxima da pilha na última avaliação
+	maxEvals     int                // se > 0, limite de chamadas de evalRPN (cada passo de sigma conta); usado pela API
+	evals        int                // chamadas de evalRPN feitas, contadas só com maxEvals
+}
+
+// angleUnits dá o valor em radianos de uma unidade de cada modo angular.
+var angleUnits = map[string]float64{"rad": 1, "deg": math.Pi / 180, "grad": math.Pi / 200}
+
+// toRadians converte um ângulo na unidade atual para radianos.
+func (c *EvalContext) toRadians(x float64) float64 {
+	if f, ok := angleUnits[c.angleUnit]; ok {
+		return x * f
+	}
+	return x
+}
+
+// fromRadians converte um ângulo em radianos para a unidade atual.
+func (c *EvalContext) fromRadians(x float64) float64 {
+	if f, ok := angleUnits[c.angleUnit]; ok {
+		return x / f
+	}
+	return x
+}
+
+// fullTurn é uma volta completa na unidade atual: 2π, 360 ou 400.
+func (c *EvalContext) fullTurn() float64 { return c.fromRadians(2 * math.Pi) }
+
+// normalizeAngle reduz um ângulo a [0, volta completa).
+func (c *EvalContext) normalizeAngle(x float64) float64 {
+	turn := c.fullTurn()
+	r := math.Mod(x, turn)
+	if r < 0 {
+		r += turn
+	}
+	if r == turn {
+		r = 0 // -1e-17 + 2π arredonda para 2π
+	}
+	return r
+}
+
+// angleString escreve um ângulo em radianos na unidade atual: 45°, 50 grad
+// ou 0.785398163397 rad.
+func (c *EvalContext) angleString(x float64) string {
+	switch v := c.fromRadians(x); c.angleUnit {
+	case "deg":
+		return fmt.Sprintf("%.12g°", v)
+	case "grad":
+		return fmt.Sprintf("%.12g grad", v)
+	default:
+		return fmt.Sprintf("%.12g rad", v)
+	}
+}
+
+// ExprCache guarda resultados já calculados, indexados pela forma pós-fixa
+// normalizada da expressão e pelos valores das variáveis que ela lê. Tem de
+// ser limpa sempre que muda algo de que os resultados dependam (:intmode,
+// :rad/:deg/:grad).
+type ExprCache map[string]float64
+
+// setVar guarda o resultado de uma atribuição: o valor em vars e, se o
+// resultado foi exato, um intervalo ou tem unidade, o resultado inteiro em
+// varResults, para que :exact, :interval e :units o leiam sem passar pelo
+// float64 (x = 1/3 continua a ser 1/3, x = interval(1, 2) não fica reduzido ao
+// ponto médio e x = 3 [m] continua em metros).
+func (c *EvalContext) setVar(name string, r result) {
+	c.vars[name] = r.val
+	if r.exact == nil && r.interval == nil && (r.unit == nil || *r.unit == dimensionless) {
+		delete(c.varResults, name)
+		return
+	}
+	if c.varResults == nil {
+		c.varResults = map[string]result{}
+	}
+	c.varResults[name] = r
+}
+
+// invalidateCache esvazia a cache, se estiver ligada.
+func (c *EvalContext) invalidateCache() {
+	if c.cache != nil {
+		c.cache = ExprCache{}
+	}
+}
+
+// evalCached é evalRPN com a cache do contexto. Expressões que usam ans,
+// sigma ou funções com estado (polyeval, polyfit) e as que geram avisos não
+// são guardadas. Cada variável entra na chave com o valor atual, para que
+// x = 2 e depois x = 3 não partilhem o resultado.
+func evalCached(rpn []token, ctx *EvalContext) (float64, error) {
+	if ctx.cache == nil {
+		return evalRPN(rpn, ctx)
+	}
+	key := make([]byte, 0, 64)
+	for _, t := range rpn {
+		if (t.typ == tIdent && t.val == "ans") || t.lazy != nil || (t.typ == tFunc && functions[t.val].state) {
+			return evalRPN(rpn, ctx)
+		}
+		key = append(append(key, t.val...), ' ')
+		if v, ok := ctx.vars[t.val]; ok && t.typ == tIdent {
+			key = strconv.AppendFloat(append(key[:len(key)-1], '='), v, 'g', -1, 64)
+			key = append(key, ' ')
+		}
+	}
+	if v, ok := ctx.cache[string(key)]; ok {
+		return v, nil
+	}
+	res, err := evalRPN(rpn, ctx)
+	if err == nil && len(ctx.notes) == 0 {
+		ctx.cache[string(key)] = res
+	}
+	return res, err
+}
+
+// note regista um aviso a mostrar junto do resultado.
+func (c *EvalContext) note(format string, a ...any) {
+	c.notes = append(c.notes, fmt.Sprintf(format, a...))
+}
+
+// trace mostra um passo da avaliação no modo :verbose: o token, os valores
+// tirados da pilha, o resultado empilhado e a pilha final. Os passos das
+// avaliações encaixadas (a expressão de sigma, ...) ficam mais indentados.
+func (c *EvalContext) trace(t token, popped, st []float64) {
+	num := func(v float64) string { return strconv.FormatFloat(v, 'g', 15, 64) }
+	vals := make([]string, len(st))
+	for i, v := range st {
+		vals[i] = num(v)
+	}
+	stack := "[" + strings.Join(vals, " ") + "]"
+	indent := strings.Repeat("  ", c.depth)
+	if popped == nil {
+		c.note("%s"+msg("verbose_push"), indent, t.val, num(st[len(st)-1]), stack)
+		return
+	}
+	args := make([]string, len(popped))
+	for i, v := range popped {
+		args[i] = num(v)
+	}
+	c.note("%s"+msg("verbose_apply"), indent, t.val, strings.Join(args, ","), num(st[len(st)-1]), stack)
+}
+
+func evalRPN(rpn []token, ctx *EvalContext) (float64, error) {
+	if ctx.maxEvals > 0 {
+		if ctx.evals++; ctx.evals > ctx.maxEvals {
+			return 0, fmt.Errorf(msg("eval_budget"), ctx.maxEvals)
+		}
+	}
+	if ctx.verbose {
+		ctx.depth++
+		defer func() { ctx.depth-- }()
+	}
+	var st []float64
+	for _, t := range rpn {
+		switch t.typ {
+		case tNumber:
+			v, err := strconv.ParseFloat(t.val, 64)
+			if err != nil {
+				return 0, err
+			}
+			st = append(st, v)
+			if ctx.verbose {
+				ctx.trace(t, nil, st)
+			}
+		case tIdent:
+			if t.val == "ans" {
+				st = append(st, ctx.lastAns)
+			} else if isUnit(t) {
+				st = append(st, 1) // as unidades só contam em evalUnits
+			} else if c, ok := constants[t.val]; ok {
+				st = append(st, c)
+			} else if v, ok := ctx.vars[t.val]; ok {
+				st = append(st, v)
+			} else {
+				return 0, errAt(t.offset, fmt.Errorf(msg("unknown_ident"), t.val))
+			}
+			if ctx.verbose {
+				ctx.trace(t, nil, st)
+			}
+		case tOp:
+			if ops[t.val].unary {
+				if len(st) < 1 {
+					return 0, errAt(t.offset, errorFormatter(ctx).EvalError(t.val, st))
+				}
+				b := st[len(st)-1]
+				st = st[:len(st)-1]
+				start := ctx.profile.start()
+				res := ops[t.val].fn(0, b)
+				ctx.profile.record(t.val, start)
+				st = append(st, res)
+				if ctx.verbose {
+					ctx.trace(t, []float64{b}, st)
+				}
+			} else {
+				if len(st) < 2 {
+					return 0, errAt(t.offset, errorFormatter(ctx).EvalError(t.val, st))
+				}
+				b := st[len(st)-1]
+				a := st[len(st)-2]
+				st = st[:len(st)-2]
+				if (t.val == "/" || t.val == "//") && b == 0 {
+					return 0, errAt(t.offset, errorFormatter(ctx).EvalError(t.val, []float64{a, b}))
+				}
+				start := ctx.profile.start()
+				res := ops[t.val].fn(a, b)
+				ctx.profile.record(t.val, start)
+				if t.val == "/" && ctx.intMode && res != math.Floor(res) {
+					ctx.note(msg("int_truncated"), a, b, math.Floor(res))
+					res = math.Floor(res)
+				}
+				st = append(st, res)
+				if ctx.verbose {
+					ctx.trace(t, []float64{a, b}, st)
+				}
+			}
+		case tFunc:
+			nargs, err := argCount(t)
+			if err != nil {
+				return 0, err
+			}
+			if len(st) < nargs {
+				return 0, errAt(t.offset, fmt.Errorf(msg("func_few_args"), t.val))
+			}
+			args := st[len(st)-nargs:]
+			st = st[:len(st)-nargs]
+			if ctx.verbose {
+				// o resultado vai ocupar o lugar de args[0]
+				args = append([]float64{}, args...)
+			}
+			var res float64
+			start := ctx.profile.start()
+			if t.lazy != nil {
+				res, err = evalLazy(t, args, ctx)
+			} else {
+				res, err = functions[t.val].fn(ctx, args...)
+			}
+			ctx.profile.record(t.val, start)
+			if err != nil {
+				return 0, err
+			}
+			st = append(st, res)
+			if ctx.verbose {
+				ctx.trace(t, args, st)
+			}
+		}
+		if ctx.stackDepth && len(st) > ctx.maxDepth {
+			ctx.maxDepth = len(st)
+		}
+	}
+	if len(st) != 1 {
+		return 0, errors.New(msg("invalid_expr"))
+	}
+	return st[0], nil
+}
+
+// compile converte uma expressão infixa na sua forma pós-fixa.
+func compile(expr string, ctx *EvalContext) ([]token, error) {
+	rpns, err := compileAll(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rpns) > 1 {
+		return nil, errors.New(msg("plusminus_multi"))
+	}
+	return rpns[0], nil
+}
+
+// compileAll é como compile, mas expande cada ± nas duas expressões possíveis
+// (com + e com -), devolvendo a forma pós-fixa de cada uma.
+func compileAll(expr string, ctx *EvalContext) ([][]token, error) {
+	toks, err := tokenize(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return compileTokens(toks, ctx)
+}
+
+// compileTokens é a segunda metade de compileAll, para quem já tem os tokens.
+func compileTokens(toks []token, ctx *EvalContext) ([][]token, error) {
+	var rpns [][]token
+	for _, variant := range expandPlusMinus(toks) {
+		rpn, err := shuntingYard(variant, ctx)
+		if err != nil {
+			return nil, err
+		}
+		rpns = append(rpns, rpn)
+	}
+	return rpns, nil
+}
+
+// expandPlusMinus substitui o primeiro ± por + e por -, recursivamente.
+func expandPlusMinus(toks []token) [][]token {
+	for i, t := range toks {
+		if t.typ != tOp || (t.val != "±" && t.val != "u±") {
+			continue
+		}
+		var out [][]token
+		for _, sign := range []string{"+", "-"} {
+			v := append([]token(nil), toks...)
+			v[i].val = strings.Replace(t.val, "±", sign, 1)
+			out = append(out, expandPlusMinus(v)...)
+		}
+		return out
+	}
+	return [][]token{toks}
+}
+
+func evalExpr(expr string, ctx *EvalContext) (float64, error) {
+	rpn, err := compile(expr, ctx)
+	if err != nil {
+		return 0, err
+	}
+	return evalRPN(rpn, ctx)
+}
+
+// custo estimado de cada operação; as funções não listadas custam defaultFuncCost
+var opCost = map[string]int{
+	"|": 1, "+": 1, "-": 1, "u-": 1, "u+": 1, "*": 2, "/": 2, "//": 2, "^": 3,
+	"sin": 5, "cos": 5, "tan": 5, "log": 5, "ln": 5, "sqrt": 3,
+}
+
+const defaultFuncCost = 10
+
+// Complexity estima o custo de avaliar uma expressão pós-fixa, somando o peso
+// de cada operação. Números e identificadores não contam.
+func Complexity(rpn []token) int {
+	cost := 0
+	for _, t := range rpn {
+		switch t.typ {
+		case tOp, tFunc:
+			if c, ok := opCost[t.val]; ok {
+				cost += c
+			} else {
+				cost += defaultFuncCost
+			}
+			for _, l := range t.lazy {
+				cost += Complexity(l)
+			}
+		}
+	}
+	return cost
+}
+
+// isInteractive indica se a entrada vem de um terminal e não de um pipe ou ficheiro.
+func isInteractive(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// PrettyPrint reconstrói uma expressão infixa canónica a partir dos tokens:
+// espaços à volta dos operadores binários e só os parênteses necessários.
+// Se os tokens não formarem uma expressão válida, junta-os por espaços.
+func PrettyPrint(toks []token) string {
+	if rpn, err := shuntingYard(toks, nil); err == nil {
+		if s, err := RPNToInfix(rpn); err == nil {
+			return s
+		}
+	}
+	parts := make([]string, len(toks))
+	for i, t := range toks {
+		parts[i] = t.val
+	}
+	return strings.Join(parts, " ")
+}
+
+// RPNToInfix reconstrói a forma infixa de uma lista pós-fixa. Simula evalRPN
+// com uma pilha de strings, guardando a precedência de cada subexpressão para
+// decidir onde são precisos parênteses.
+func RPNToInfix(rpn []token) (string, error) {
+	type sub struct {
+		s    string
+		prec int
+	}
+	const atom = 100 // números, identificadores e chamadas de função
+	const cond = -3  // c ? a : b liga menos do que qualquer operador
+	if len(rpn) == 1 && rpn[0].typ == tFunc && rpn[0].argc == 0 && rpn[0].lazy == nil {
+		// um nome de função sozinho, como sin em integral_approx(sin, 0, pi)
+		return rpn[0].val, nil
+	}
+	var st []sub
+	for _, t := range rpn {
+		switch t.typ {
+		case tNumber, tIdent:
+			st = append(st, sub{t.val, atom})
+		case tString:
+			st = append(st, sub{`"` + t.val + `"`, atom})
+		case tOp:
+			op := ops[t.val]
+			if op.unary {
+				if len(st) < 1 {
+					return "", errors.New(msg("unary_no_operand"))
+				}
+				b := st[len(st)-1]
+				if b.prec < op.prec {
+					b.s = "(" + b.s + ")"
+				}
+				if op.postfix {
+					st[len(st)-1] = sub{b.s + t.val, op.prec}
+					continue
+				}
+				st[len(st)-1] = sub{t.val[1:] + b.s, op.prec}
+			} else {
+				if len(st) < 2 {
+					return "", errors.New(msg("binary_few_operands"))
+				}
+				a, b := st[len(st)-2], st[len(st)-1]
+				st = st[:len(st)-2]
+				if a.prec < op.prec || (a.prec == op.prec && op.rightAssoc) {
+					a.s = "(" + a.s + ")"
+				}
+				if b.prec < op.prec || (b.prec == op.prec && !op.rightAssoc) {
+					b.s = "(" + b.s + ")"
+				}
+				st = append(st, sub{a.s + " " + t.val + " " + b.s, op.prec})
+			}
+		case tFunc:
+			n, err := argCount(t)
+			if err != nil {
+				return "", err
+			}
+			if len(st) < n {
+				return "", fmt.Errorf(msg("func_few_args"), t.val)
+			}
+			if t.cond {
+				// associativo à direita: só a condição precisa de parênteses
+				// quando é outro condicional
+				c, a, b := st[len(st)-3], st[len(st)-2], st[len(st)-1]
+				st = st[:len(st)-3]
+				if c.prec <= cond {
+					c.s = "(" + c.s + ")"
+				}
+				st = append(st, sub{c.s + " ? " + a.s + " : " + b.s, cond})
+				continue
+			}
+			var args []string
+			for _, l := range t.lazy {
+				a, err := RPNToInfix(l)
+				if err != nil {
+					return "", err
+				}
+				args = append(args, a)
+			}
+			for _, a := range st[len(st)-n:] {
+				args = append(args, a.s)
+			}
+			st = st[:len(st)-n]
+			st = append(st, sub{t.val + "(" + strings.Join(args, ", ") + ")", atom})
+		}
+	}
+	if len(st) != 1 {
+		return "", errors.New(msg("invalid_expr"))
+	}
+	return st[0].s, nil
+}
+
+// parseRPN lê uma expressão já em notação pós-fixa, com tokens separados por
+// espaços, ex.: "2 3 + 4 *". O menos unário escreve-se "neg".
+func parseRPN(input string) ([]token, error) {
+	var rpn []token
+	rest := input
+	for {
+		rest = strings.TrimLeft(rest, " \t\"'")
+		if rest == "" {
+			break
+		}
+		off := len(input) - len(rest)
+		f, _, _ := strings.Cut(rest, " ")
+		f = strings.TrimRight(f, "\"'")
+		rest = rest[len(f):]
+		low := strings.ToLower(f)
+		if low == "neg" {
+			low = "u-"
+		}
+		if _, ok := ops[low]; ok {
+			rpn = append(rpn, token{typ: tOp, val: low, offset: off})
+		} else if _, ok := functions[low]; ok {
+			rpn = append(rpn, token{typ: tFunc, val: low, offset: off})
+		} else if _, err := strconv.ParseFloat(f, 64); err == nil {
+			rpn = append(rpn, token{typ: tNumber, val: f, offset: off})
+		} else if isIdentStart(rune(low[0])) {
+			rpn = append(rpn, token{typ: tIdent, val: low, offset: off})
+		} else {
+			return nil, errAt(off, fmt.Errorf(msg("invalid_token"), f))
+		}
+	}
+	return rpn, nil
+}
+
+// printDebug mostra os tokens, a forma pós-fixa e a sua reconstrução infixa.
+func printDebug(out io.Writer, expr string, ctx *EvalContext) {
+	rpns, err := compileAll(expr, ctx)
+	if err != nil {
+		return
+	}
+	for _, rpn := range rpns {
+		vals := make([]string, len(rpn))
+		for i, t := range rpn {
+			vals[i] = t.val
+		}
+		fmt.Fprintln(out, "  RPN:   ", strings.Join(vals, " "))
+		if s, err := RPNToInfix(rpn); err == nil {
+			fmt.Fprintln(out, msg("debug_infix"), s)
+		}
+	}
+}
+
+// printNotes mostra os avisos gerados pela última avaliação.
+func printNotes(out io.Writer, ctx *EvalContext) {
+	for _, n := range ctx.notes {
+		fmt.Fprintln(out, n)
+	}
+}
+
+func printHelp(out io.Writer) {
+	fmt.Fprintln(out, msg("help"))
+}
+
+// printFuncs lista as funções, uma por linha com a assinatura e a descrição,
+// ou mostra os detalhes de uma delas (:func sin), com o exemplo avaliado.
+func (s *Session) printFuncs(name string) error {
+	if name == "" {
+		printHelpEntries(s.out, helpOfKind(helpFunction))
+		return nil
+	}
+	d, ok := functions[name]
+	if !ok {
+		return fmt.Errorf(msg("unsupported_func"), name)
+	}
+	fmt.Fprintf(s.out, "%s — %s\n", d.sig, d.description())
+	if d.arity < 0 {
+		fmt.Fprintf(s.out, "  "+msg("func_min_arity")+"\n", -d.arity)
+	} else {
+		fmt.Fprintf(s.out, "  "+msg("func_arity")+"\n", d.arity)
+	}
+	v, err := evalExpr(d.example, &EvalContext{vars: map[string]float64{}})
+	if err != nil {
+		// o exemplo depende do estado da sessão (polyeval precisa de :poly)
+		fmt.Fprintf(s.out, "  %s %s\n", msg("func_example"), d.example)
+		return nil
+	}
+	fmt.Fprintf(s.out, "  %s %s = %s\n", msg("func_example"), d.example, s.formatValue(v))
+	return nil
+}
+
+// Session guarda o estado do REPL entre linhas, seja no modo interativo,
+// num ficheiro passado com --file ou num :script.
+type Session struct {
+	ctx             *EvalContext
+	in              *bufio.Scanner
+	out             io.Writer // resultados e mensagens (os.Stdout em main)
+	errOut          io.Writer // erros (os.Stderr em main)
+	maxCost         int
+	interactive     bool
+	strict          bool            // pára um ficheiro no primeiro erro
+	continueOnError bool            // numa linha com ;, um erro não pára as expressões seguintes
+	nowarn          map[string]bool // avisos desligados com :nowarn
+	precision       int             // casas decimais fixas (:precision); -1 = automático
+	format          string          // "default", "sci" ou "frac" (:format)
+	bases           []string        // bases de :multibase; nil quando desligado
+	timing          bool            // :time, mostra quanto demorou cada avaliação
+	percent         bool            // :percent, mostra os resultados em percentagem
+
+	history       []string  // expressões das sessões anteriores e desta (:history)
+	historyLoaded int       // quantas vieram do ficheiro
+	historyFile   string    // "" quando o histórico não é guardado
+	log           *os.File  // registo de CALC_LOG; nil quando desligado
+	logged        []float64 // valores avaliados na expressão em curso, para o registo
+
+	templates  map[string]template // fórmulas de :def
+	macros     map[string]string   // corpos das macros de :macro
+	macroDepth int                 // macros em execução, umas dentro das outras
+	lastExpr   string              // última expressão avaliada, para !x=5
+	lastLine   string              // última linha que não é um comando, para :repeat
+	undo       *SessionSnapshot    // estado antes da última linha, para :undo
+}
+
+// SessionSnapshot é o estado que uma linha pode mudar: ans e as variáveis.
+type SessionSnapshot struct {
+	line       string
+	ans        result
+	vars       map[string]float64
+	varResults map[string]result
+}
+
+func (s *Session) snapshot(line string) *SessionSnapshot {
+	c := s.ctx
+	return &SessionSnapshot{
+		line:       line,
+		ans:        result{val: c.lastAns, exact: c.exactAns, interval: c.intervalAns, unit: c.unitAns},
+		vars:       maps.Clone(c.vars),
+		varResults: maps.Clone(c.varResults),
+	}
+}
+
+// undoLast troca o estado atual pelo de antes da última linha; um segundo
+// :undo volta a aplicá-la.
+func (s *Session) undoLast() error {
+	if s.undo == nil {
+		return errors.New(msg("undo_none"))
+	}
+	cur := s.snapshot(s.undo.line)
+	s.setAns(s.undo.ans)
+	s.ctx.vars = s.undo.vars
+	s.ctx.varResults = s.undo.varResults
+	s.ctx.invalidateCache()
+	fmt.Fprintf(s.out, msg("undone")+"\n", s.undo.line)
+	s.undo = cur
+	return nil
+}
+
+// newSession cria uma sessão que lê de in e escreve em out e errOut; só é
+// interativa se in for um terminal.
+func newSession(in io.Reader, out, errOut io.Writer) *Session {
+	return &Session{
+		ctx: &EvalContext{
+			vars:         map[string]float64{},
+			decimalComma: localeUsesComma(os.Getenv("CALC_LOCALE")),
+			angleUnit:    "rad",
+		},
+		in:          bufio.NewScanner(in),
+		out:         out,
+		errOut:      errOut,
+		maxCost:     1000,
+		interactive: isInteractive(in),
+		nowarn:      map[string]bool{},
+		templates:   map[string]template{},
+		macros:      map[string]string{},
+		precision:   -1,
+		format:      "default",
+	}
+}
+
+// execLine processa uma linha: comando, atribuição ou expressão.
+// Devolve quit=true quando é pedida a saída.
+func (s *Session) execLine(line string) (quit bool, err error) {
+	if strings.HasPrefix(line, ":") {
+		return s.command(line)
+	}
+	s.lastLine = line
+	snap := s.snapshot(line)
+	defer func() {
+		if err == nil {
+			s.undo = snap
+		}
+	}()
+	if rest, ok := strings.CutPrefix(line, "eval "); ok {
+		return false, s.printRangeTable(rest)
+	}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		results, err := s.whatIf(rest)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range results {
+			fmt.Fprintln(s.out, "=", s.formatResult(r))
+		}
+		return false, nil
+	}
+	if results, ok, err := s.evalWhere(line); ok {
+		if err != nil {
+			return false, err
+		}
+		for _, r := range results {
+			fmt.Fprintln(s.out, "=", s.formatResult(r))
+		}
+		return false, nil
+	}
+	if name, expr, ok := parseAssignment(line); ok {
+		res, err := s.eval(expr)
+		if err != nil {
+			var pe *posError
+			if errors.As(err, &pe) {
+				pe.offset += len(line) - len(expr)
+			}
+			return false, err
+		}
+		s.ctx.setVar(name, res)
+		s.ctx.invalidateCache()
+		fmt.Fprintf(s.out, "%s = %s\n", name, s.formatResult(res))
+		if name == "x" && s.ctx.poly != nil {
+			// modo polinómio: cada x = ... mostra também p(x)
+			fmt.Fprintf(s.out, "p(%s) = %s\n", s.formatValue(res.val), s.formatValue(horner(res.val, s.ctx.poly)))
+		}
+		return false, nil
+	}
+	start := time.Now()
+	results, err := s.evalAll(line)
+	if err != nil {
+		return false, err
+	}
+	s.lastExpr = line
+	elapsed := time.Since(start)
+	for i, r := range results {
+		out := s.formatResult(r)
+		if s.timing && i == len(results)-1 {
+			out += " " + fmt.Sprintf(msg("evaluated_in"), s.averageTime(line, elapsed))
+		}
+		fmt.Fprintln(s.out, "=", out)
+	}
+	return false, nil
+}
+
+// result é o valor de uma avaliação; no modo :exact, exact guarda o racional
+// sem perda de precisão (nil se a expressão não era racional).
+type result struct {
+	val      float64
+	exact    *big.Rat
+	interval *Interval   // no modo :interval; val é o ponto médio
+	unit     *UnitVector // no modo :units
+}
+
+// formatResult formata um resultado segundo :precision e :format; os
+// racionais exatos mostram-se sempre com todos os dígitos, como inteiro ou
+// fração irredutível (1/2).
+func (s *Session) formatResult(r result) string {
+	if s.percent {
+		return formatPercent(r.val, s.precision)
+	}
+	var out string
+	if r.interval != nil {
+		return "[" + s.formatValue(r.interval.lo) + ", " + s.formatValue(r.interval.hi) + "]"
+	}
+	if r.unit != nil && *r.unit != dimensionless {
+		return s.formatValue(r.val) + " " + unitLabel(*r.unit)
+	}
+	if r.exact != nil {
+		out = r.exact.RatString()
+	} else {
+		out = s.formatValue(r.val)
+	}
+	if s.bases != nil {
+		var n *big.Int
+		var ok bool
+		switch {
+		case r.exact != nil:
+			n, ok = r.exact.Num(), r.exact.IsInt()
+		case math.Abs(r.val) < 1<<63:
+			n, ok = floatToInt(r.val)
+		}
+		if ok {
+			if mb := multibaseString(n, s.bases); mb != "" {
+				out += "  (" + mb + ")"
+			}
+		}
+	}
+	return out
+}
+
+// basePrefixes são as bases de :multibase, com o prefixo e a base numérica.
+var basePrefixes = map[string]struct {
+	prefix string
+	base   int
+}{
+	"dec": {"", 10}, "hex": {"0x", 16}, "bin": {"0b", 2}, "oct": {"0o", 8},
+}
+
+// multibaseString escreve o inteiro n nas bases pedidas, exceto a decimal,
+// que já é a do resultado: 255 → "0xFF | 0b11111111 | 0o377".
+func multibaseString(n *big.Int, bases []string) string {
+	var parts []string
+	for _, b := range bases {
+		if b == "dec" {
+			continue
+		}
+		bp := basePrefixes[b]
+		digits := strings.ToUpper(new(big.Int).Abs(n).Text(bp.base))
+		if n.Sign() < 0 {
+			parts = append(parts, "-"+bp.prefix+digits)
+		} else {
+			parts = append(parts, bp.prefix+digits)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// formatValue formata um número. Por omissão os inteiros abaixo de 1e15
+// aparecem sem ponto decimal nem notação científica (4, não 4.0 nem 4e+00).
+func (s *Session) formatValue(v float64) string {
+	switch {
+	case s.format == "sci" && s.precision >= 0:
+		return strconv.FormatFloat(v, 'e', s.precision, 64)
+	case s.format == "sci":
+		return strconv.FormatFloat(v, 'e', -1, 64)
+	case s.format == "frac" && !math.IsInf(v, 0) && !math.IsNaN(v) && math.Abs(v) < 1<<53:
+		return formatFrac(v)
+	case s.precision >= 0:
+		return strconv.FormatFloat(v, 'f', s.precision, 64)
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return fmt.Sprintf("%.15g", v)
+}
+
+// formatPercent escreve v como percentagem (0.5 → 50.0%), com prec casas
+// decimais, ou, com prec < 0, as necessárias (pelo menos uma).
+func formatPercent(v float64, prec int) string {
+	p := v * 100
+	if prec >= 0 {
+		return strconv.FormatFloat(p, 'f', prec, 64) + "%"
+	}
+	out := fmt.Sprintf("%.15g", p)
+	if !strings.ContainsAny(out, ".eIN") {
+		out += ".0"
+	}
+	return out + "%"
+}
+
+// maxFracDenom é o maior denominador mostrado por :format frac.
+const maxFracDenom = 1000
+
+// formatFrac escreve v como fração (3/4, ou 2 se for inteiro). Quando a fração
+// exata de v tem denominador acima de maxFracDenom, mostra a melhor
+// aproximação com denominador até esse limite, com "~" se não for igual a v.
+func formatFrac(v float64) string {
+	r := new(big.Rat).SetFloat64(v)
+	if r.Denom().Cmp(big.NewInt(maxFracDenom)) <= 0 {
+		return r.RatString()
+	}
+	p, q := bestRational(math.Abs(v), maxFracDenom)
+	if v < 0 {
+		p = -p
+	}
+	r.SetFrac64(p, q)
+	if float64(p)/float64(q) == v {
+		return r.RatString() // 1/3 calculado em float64
+	}
+	return "~" + r.RatString()
+}
+
+// bestRational devolve a melhor aproximação p/q de x >= 0 com q <= maxDen,
+// pelas convergentes da fração contínua de x e, no fim, pela melhor
+// semiconvergente.
+func bestRational(x float64, maxDen int64) (int64, int64) {
+	h0, h1 := int64(0), int64(1) // numeradores p(k-2), p(k-1)
+	k0, k1 := int64(1), int64(0) // denominadores q(k-2), q(k-1)
+	v := x
+	for {
+		a := int64(math.Floor(x))
+		if a*k1+k0 > maxDen {
+			t := (maxDen - k0) / k1
+			hs, ks := t*h1+h0, t*k1+k0
+			if math.Abs(float64(hs)/float64(ks)-v) < math.Abs(float64(h1)/float64(k1)-v) {
+				return hs, ks
+			}
+			return h1, k1
 		}
-		if a[0] < a[1] {
-			return a[0], nil
+		h0, h1 = h1, a*h1+h0
+		k0, k1 = k1, a*k1+k0
+		frac := x - math.Floor(x)
+		if frac < 1e-12 {
+			return h1, k1
 		}
-		return a[1], nil
-	},
+		x = 1 / frac
+	}
 }
 
-var constants = map[string]float64{
-	"pi": math.Pi,
-	"e":  math.E,
+// eval avalia uma expressão com um único resultado e atualiza ans.
+func (s *Session) eval(expr string) (result, error) {
+	results, err := s.evalAll(expr)
+	if err != nil {
+		return result{}, err
+	}
+	if len(results) > 1 {
+		return result{}, errors.New(msg("plusminus_multi"))
+	}
+	return results[0], nil
 }
 
-func isIdentStart(r rune) bool { return unicode.IsLetter(r) || r == '_' }
-func isIdent(r rune) bool      { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+// evalAll avalia uma expressão, pedindo confirmação se for cara, e devolve um
+// resultado por cada combinação de ±. ans fica com o primeiro.
+func (s *Session) evalAll(expr string) ([]result, error) {
+	if s.ctx.debug {
+		printDebug(s.out, expr, s.ctx)
+	}
+	rpns, err := compileAll(expr, s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.evalCompiled(rpns)
+}
 
-func tokenize(input string) ([]token, error) {
-	var toks []token
-	s := strings.TrimSpace(input)
-	i := 0
-	prevType := tOp // como se começasse com operador
-	for i < len(s) {
-		ch := rune(s[i])
-		if unicode.IsSpace(ch) {
-			i++
-			continue
+// evalCompiled é a segunda metade de evalAll: avalia as formas pós-fixas já
+// compiladas, pedindo confirmação se forem caras.
+func (s *Session) evalCompiled(rpns [][]token) ([]result, error) {
+	var err error
+	cost := 0
+	for _, rpn := range rpns {
+		cost += Complexity(rpn)
+	}
+	if s.interactive && cost > s.maxCost {
+		fmt.Fprint(s.out, msg("slow_confirm"))
+		if !s.in.Scan() {
+			return nil, errors.New(msg("eval_cancelled"))
 		}
-		if unicode.IsDigit(ch) || ch == '.' {
-			j := i + 1
-			hasE := false
-			for j < len(s) {
-				r := rune(s[j])
-				if unicode.IsDigit(r) || r == '.' {
-					j++
-				} else if (r == 'e' || r == 'E') && !hasE {
-					hasE = true
-					j++
-					if j < len(s) && (s[j] == '+' || s[j] == '-') {
-						j++
-					}
-				} else {
-					break
-				}
-			}
-			toks = append(toks, token{tNumber, s[i:j]})
-			prevType = tNumber
-			i = j
-			continue
+		ans := strings.ToLower(strings.TrimSpace(s.in.Text()))
+		if ans != "s" && ans != "sim" && ans != "y" && ans != "yes" {
+			return nil, errors.New(msg("eval_cancelled"))
 		}
-		switch ch {
-		case '+', '-':
-			op := string(ch)
-			if prevType == tOp || prevType == tLParen || len(toks) == 0 {
-				if op == "-" {
-					op = "u-"
-				} else {
-					op = "u+"
-				}
-			}
-			toks = append(toks, token{tOp, op})
-			prevType = tOp
-			i++
-		case '*', '/', '^':
-			toks = append(toks, token{tOp, string(ch)})
-			prevType = tOp
-			i++
-		case '(':
-			toks = append(toks, token{tLParen, "("})
-			prevType = tLParen
-			i++
-		case ')':
-			toks = append(toks, token{tRParen, ")"})
-			prevType = tRParen
-			i++
-		case ',':
-			toks = append(toks, token{tComma, ","})
-			prevType = tComma
-			i++
-		default:
-			if isIdentStart(ch) {
-				j := i + 1
-				for j < len(s) && isIdent(rune(s[j])) {
-					j++
-				}
-				id := s[i:j]
-				low := strings.ToLower(id)
-				if _, ok := functions[low]; ok {
-					toks = append(toks, token{tFunc, low})
-				} else if _, ok := constants[low]; ok || low == "ans" {
-					toks = append(toks, token{tIdent, low})
-				} else {
-					return nil, fmt.Errorf("identificador desconhecido: %s", id)
-				}
-				prevType = tIdent
-				i = j
-			} else {
-				return nil, fmt.Errorf("caractere inválido: %q", ch)
-			}
+	}
+	results := make([]result, len(rpns))
+	for i, rpn := range rpns {
+		if results[i], err = s.evalRPN(rpn); err != nil {
+			return nil, err
 		}
 	}
-	return toks, nil
+	s.setAns(results[0])
+	return results, nil
 }
 
-func shuntingYard(toks []token) ([]token, error) {
-	var output []token
-	var stack []token
-	arity := map[string]int{
-		"sin": 1, "cos": 1, "tan": 1, "sqrt": 1, "log": 1, "ln": 1,
-		"abs": 1, "floor": 1, "ceil": 1, "round": 1, "max": 2, "min": 2,
+// evalRPN avalia uma forma pós-fixa; no modo :exact tenta primeiro big.Rat e no
+// modo :interval usa aritmética de intervalos; no modo :units leva as
+// unidades. Cada valor calculado fica em s.logged, para o registo.
+func (s *Session) evalRPN(rpn []token) (r result, err error) {
+	s.ctx.notes = nil
+	defer printNotes(s.out, s.ctx)
+	defer func() {
+		if err == nil {
+			s.logged = append(s.logged, r.val)
+		}
+	}()
+	if s.ctx.interval {
+		iv, err := evalInterval(rpn, s.ctx)
+		return result{val: iv.mid(), interval: &iv}, err
 	}
-	for _, t := range toks {
-		switch t.typ {
-		case tNumber, tIdent:
-			output = append(output, t)
-		case tFunc:
-			stack = append(stack, t)
-		case tComma:
-			for len(stack) > 0 && stack[len(stack)-1].typ != tLParen {
-				output = append(output, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
-			}
-			if len(stack) == 0 {
-				return nil, errors.New("vírgula fora de função")
+	if s.ctx.units {
+		q, err := evalUnits(rpn, s.ctx)
+		return result{val: q.v, unit: &q.u}, err
+	}
+	if s.ctx.exact {
+		n, err := evalExact(rpn, s.ctx)
+		if err == nil {
+			f, _ := n.Float64()
+			return result{val: f, exact: n}, nil
+		}
+		if err != errNotExact {
+			return result{}, err
+		}
+	}
+	s.ctx.maxDepth = 0
+	res, err := evalCached(rpn, s.ctx)
+	if err == nil && s.ctx.stackDepth && s.ctx.maxDepth > 0 {
+		s.ctx.note(msg("stack_depth"), s.ctx.maxDepth)
+	}
+	if err == nil && !s.nowarn["precision"] && math.Abs(res) >= 1<<53 && res == math.Trunc(res) && !math.IsInf(res, 0) {
+		ulp := math.Nextafter(math.Abs(res), math.Inf(1)) - math.Abs(res)
+		s.ctx.note(msg("precision_loss"), ulp)
+	}
+	return result{val: res}, err
+}
+
+func (s *Session) setAns(r result) {
+	s.ctx.lastAns = r.val
+	s.ctx.exactAns = r.exact
+	s.ctx.intervalAns = r.interval
+	s.ctx.unitAns = r.unit
+}
+
+// parseAssignment reconhece linhas da forma "nome = expr".
+func parseAssignment(line string) (name, expr string, ok bool) {
+	name, expr, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || !isIdentStart(rune(name[0])) || strings.HasPrefix(expr, "=") {
+		return "", "", false
+	}
+	for _, r := range name {
+		if !isIdent(r) {
+			return "", "", false
+		}
+	}
+	name = strings.ToLower(name)
+	if _, ok := functions[name]; ok {
+		return "", "", false
+	}
+	if _, ok := constants[name]; ok || name == "ans" {
+		return "", "", false
+	}
+	return name, expr, true
+}
+
+func (s *Session) command(line string) (quit bool, err error) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(cmd) {
+	case ":quit", ":q", ":exit":
+		return true, nil
+	case ":help", ":h":
+		return false, s.help(arg)
+	case ":const":
+		fmt.Fprintln(s.out, msg("constants"))
+		printHelpEntries(s.out, helpOfKind(helpConstant))
+	case ":operators":
+		printHelpEntries(s.out, helpOfKind(helpOperator))
+	case ":func":
+		return false, s.printFuncs(strings.ToLower(arg))
+	case ":sizeof":
+		s.printSizeof()
+	case ":history":
+		s.printHistory()
+	case ":vars":
+		for k, v := range s.ctx.vars {
+			r, ok := s.ctx.varResults[k]
+			if !ok {
+				r = result{val: v}
 			}
-		case tOp:
-			for len(stack) > 0 && stack[len(stack)-1].typ == tOp {
-				top := stack[len(stack)-1].val
-				curr := t.val
-				if (ops[top].prec > ops[curr].prec) ||
-					(ops[top].prec == ops[curr].prec && !ops[curr].rightAssoc) {
-					output = append(output, stack[len(stack)-1])
-					stack = stack[:len(stack)-1]
-				} else {
-					break
+			fmt.Fprintf(s.out, "  %s = %s\n", k, s.formatResult(r))
+		}
+	case ":pretty":
+		toks, err := tokenize(arg, s.ctx)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(s.out, PrettyPrint(toks))
+	case ":simplify":
+		return false, s.printSimplified(arg)
+	case ":expand":
+		return false, s.expand(arg)
+	case ":def":
+		return false, s.define(arg)
+	case ":roman":
+		return false, s.roman(arg)
+	case ":macro":
+		return false, s.macro(arg)
+	case ":poly":
+		return false, s.setPoly(arg)
+	case ":rpn":
+		rpn, err := parseRPN(arg)
+		if err != nil {
+			return false, err
+		}
+		if inf, err := RPNToInfix(rpn); err == nil {
+			fmt.Fprintln(s.out, msg("debug_infix"), inf)
+		}
+		res, err := s.evalRPN(rpn)
+		if err != nil {
+			return false, err
+		}
+		s.setAns(res)
+		fmt.Fprintln(s.out, "=", s.formatResult(res))
+	case ":debug":
+		s.ctx.debug = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "debug:", s.ctx.debug)
+	case ":verbose":
+		s.ctx.verbose = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "verbose:", s.ctx.verbose)
+	case ":undo":
+		return false, s.undoLast()
+	case ":watch":
+		return false, s.setWatch(arg)
+	case ":repeat":
+		return false, s.repeat(arg)
+	case ":time":
+		s.timing = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "time:", s.timing)
+	case ":stackdepth":
+		s.ctx.stackDepth = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "stackdepth:", s.ctx.stackDepth)
+	case ":maxcost":
+		if arg == "" {
+			fmt.Fprintln(s.out, "maxcost:", s.maxCost)
+			return false, nil
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return false, errors.New(msg("usage_maxcost"))
+		}
+		s.maxCost = n
+	case ":intmode":
+		s.ctx.intMode = strings.ToLower(arg) != "off"
+		s.ctx.invalidateCache()
+		fmt.Fprintln(s.out, "intmode:", s.ctx.intMode)
+	case ":rad", ":deg", ":grad":
+		s.ctx.angleUnit = strings.ToLower(cmd[1:])
+		s.ctx.invalidateCache()
+		fmt.Fprintln(s.out, "angle:", s.ctx.angleUnit)
+	case ":exact", ":approx":
+		s.ctx.exact = strings.ToLower(cmd) == ":exact" && strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "exact:", s.ctx.exact)
+	case ":csv_mode":
+		// o mesmo que CALC_LOCALE=pt, mas a meio da sessão: para colar valores
+		// de um CSV europeu, com vírgula decimal e ; entre campos
+		s.ctx.decimalComma = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "csv_mode:", s.ctx.decimalComma)
+	case ":interval":
+		s.ctx.interval = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "interval:", s.ctx.interval)
+	case ":units":
+		s.ctx.units = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "units:", s.ctx.units)
+	case ":multibase":
+		switch f := strings.Fields(strings.ToLower(arg)); {
+		case len(f) == 0:
+			s.bases = []string{"dec", "hex", "bin", "oct"}
+		case len(f) == 1 && f[0] == "off":
+			s.bases = nil
+		default:
+			for _, b := range f {
+				if _, ok := basePrefixes[b]; !ok {
+					return false, errors.New(msg("usage_multibase"))
 				}
 			}
-			stack = append(stack, t)
-		case tLParen:
-			stack = append(stack, t)
-		case tRParen:
-			for len(stack) > 0 && stack[len(stack)-1].typ != tLParen {
-				output = append(output, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
+			s.bases = f
+		}
+	case ":pi":
+		return false, printPi(s.out, arg)
+	case ":profile":
+		switch strings.ToLower(arg) {
+		case "on":
+			if s.ctx.profile == nil {
+				s.ctx.profile = Profile{}
 			}
-			if len(stack) == 0 {
-				return nil, errors.New("parênteses desbalanceados")
+		case "off":
+			s.ctx.profile = nil
+		case "clear":
+			if s.ctx.profile != nil {
+				s.ctx.profile = Profile{}
 			}
-			stack = stack[:len(stack)-1]
-			if len(stack) > 0 && stack[len(stack)-1].typ == tFunc {
-				output = append(output, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
+		case "":
+			s.ctx.profile.print(s.out)
+		default:
+			return false, errors.New(msg("usage_profile"))
+		}
+	case ":game":
+		s.playGame()
+	case ":test":
+		runSelfTests(s.out)
+	case ":example":
+		return false, s.printExamples(strings.ToLower(arg))
+	case ":sum":
+		return false, s.printSum(arg)
+	case ":graph":
+		return false, s.printGraph(arg)
+	case ":chart":
+		return false, s.printChart(arg)
+	case ":numberline":
+		return false, s.printNumberLine(arg)
+	case ":precision":
+		switch n, err := strconv.Atoi(arg); {
+		case strings.ToLower(arg) == "auto":
+			s.precision = -1
+		case err != nil || n < 0 || n > 30:
+			return false, errors.New(msg("usage_precision"))
+		default:
+			s.precision = n
+		}
+	case ":percent":
+		s.percent = strings.ToLower(arg) != "off"
+		fmt.Fprintln(s.out, "percent:", s.percent)
+	case ":format":
+		switch f := strings.ToLower(arg); f {
+		case "default", "sci", "frac":
+			s.format = f
+		default:
+			return false, errors.New(msg("usage_format"))
+		}
+	case ":nowarn", ":warn":
+		if arg != "precision" {
+			return false, errors.New(msg("usage_nowarn"))
+		}
+		s.nowarn[arg] = strings.ToLower(cmd) == ":nowarn"
+	case ":cache":
+		switch strings.ToLower(arg) {
+		case "on":
+			if s.ctx.cache == nil {
+				s.ctx.cache = ExprCache{}
 			}
+		case "off":
+			s.ctx.cache = nil
+		case "":
+		default:
+			return false, errors.New(msg("usage_cache"))
 		}
-	}
-	for len(stack) > 0 {
-		if stack[len(stack)-1].typ == tLParen {
-			return nil, errors.New("parênteses desbalanceados")
+		fmt.Fprintf(s.out, "cache: %v (%d)\n", s.ctx.cache != nil, len(s.ctx.cache))
+	case ":lang":
+		if _, ok := messages[strings.ToLower(arg)]; !ok {
+			return false, errors.New(msg("usage_lang"))
 		}
-		output = append(output, stack[len(stack)-1])
-		stack = stack[:len(stack)-1]
+		lang = strings.ToLower(arg)
+	case ":script":
+		if arg == "" {
+			return false, errors.New(msg("usage_script"))
+		}
+		return false, runFile(s, arg)
+	default:
+		if quit, ok, err := s.runMacro(strings.ToLower(cmd[1:]), arg); ok {
+			return quit, err
+		}
+		return false, errors.New(msg("unknown_command"))
 	}
-	for _, t := range output {
-		if t.typ == tFunc {
-			if _, ok := arity[t.val]; !ok {
-				return nil, fmt.Errorf("função não suportada: %s", t.val)
+	return false, nil
+}
+
+// statement é uma das expressões de uma linha separadas por ;, com a
+// posição onde começa na linha.
+type statement struct {
+	text   string
+	offset int
+}
+
+// splitStatements divide a linha nos ; fora de parênteses. Os comandos ficam
+// inteiros (o corpo de :macro tem ;). Com vírgula decimal, ; também separa
+// os argumentos de where, por isso um where fica com o resto da linha:
+// "x = 2; f where a=1,5; b=x" são duas expressões.
+func splitStatements(line string, decimalComma bool) []statement {
+	if strings.HasPrefix(line, ":") {
+		return []statement{{line, 0}}
+	}
+	var sts []statement
+	offset := 0
+	for _, part := range splitTopLevel(line, ';') {
+		if text := strings.TrimSpace(part); text != "" {
+			start := offset + strings.Index(part, text)
+			if decimalComma && whereRe.MatchString(text) {
+				return append(sts, statement{strings.TrimSpace(line[start:]), start})
 			}
+			sts = append(sts, statement{text, start})
 		}
+		offset += len(part) + 1
 	}
-	return output, nil
+	return sts
 }
 
-func evalRPN(rpn []token, lastAns float64) (float64, error) {
-	var st []float64
-	for _, t := range rpn {
-		switch t.typ {
-		case tNumber:
-			v, err := strconv.ParseFloat(t.val, 64)
-			if err != nil {
-				return 0, err
-			}
-			st = append(st, v)
-		case tIdent:
-			if t.val == "ans" {
-				st = append(st, lastAns)
-			} else if c, ok := constants[t.val]; ok {
-				st = append(st, c)
-			} else {
-				return 0, fmt.Errorf("identificador desconhecido: %s", t.val)
-			}
-		case tOp:
-			if ops[t.val].unary {
-				if len(st) < 1 {
-					return 0, errors.New("operador unário sem operando")
-				}
-				b := st[len(st)-1]
-				st = st[:len(st)-1]
-				res := ops[t.val].fn(0, b)
-				st = append(st, res)
-			} else {
-				if len(st) < 2 {
-					return 0, errors.New("operador binário com poucos operandos")
-				}
-				b := st[len(st)-1]
-				a := st[len(st)-2]
-				st = st[:len(st)-2]
-				if t.val == "/" && b == 0 {
-					return 0, errors.New("divisão por zero")
-				}
-				res := ops[t.val].fn(a, b)
-				st = append(st, res)
-			}
-		case tFunc:
-			var nargs int
-			switch t.val {
-			case "max", "min":
-				nargs = 2
-			default:
-				nargs = 1
+// execStatements executa as expressões de uma linha, por ordem. Um erro pára
+// as seguintes, a não ser com continueOnError (e sem strict); cada erro é passado a report
+// logo que acontece, com a posição relativa à linha inteira. É aqui que as
+// expressões com sucesso vão para o registo de CALC_LOG, com todos os valores
+// que calcularam (:rpn, !x=5, :repeat e as linhas de eval ... for incluídos).
+func (s *Session) execStatements(line string, report func(err error)) (quit bool, failed bool) {
+	for _, st := range splitStatements(line, s.ctx.decimalComma) {
+		s.logged = s.logged[:0]
+		quit, err := s.execLine(st.text)
+		if err == nil {
+			for _, v := range s.logged {
+				s.logResult(st.text, v)
 			}
-			if len(st) < nargs {
-				return 0, fmt.Errorf("função %s com poucos argumentos", t.val)
+		}
+		if err != nil {
+			var pe *posError
+			if errors.As(err, &pe) {
+				pe.offset += st.offset
 			}
-			args := st[len(st)-nargs:]
-			st = st[:len(st)-nargs]
-			fn := functions[t.val]
-			res, err := fn(args...)
-			if err != nil {
-				return 0, err
+			report(err)
+			failed = true
+			if !s.continueOnError || s.strict {
+				return quit, true
 			}
-			st = append(st, res)
+		}
+		if quit {
+			return true, failed
 		}
 	}
-	if len(st) != 1 {
-		return 0, errors.New("expressão inválida")
-	}
-	return st[0], nil
+	return false, failed
 }
 
-func evalExpr(expr string, lastAns float64) (float64, error) {
-	toks, err := tokenize(expr)
+// roman trata ":roman XIV": lê o numeral e guarda o valor em ans, já que o
+// analisador não tem texto como argumento de funções.
+func (s *Session) roman(arg string) error {
+	if arg == "" {
+		return errors.New(msg("usage_roman"))
+	}
+	n, err := fromRoman(arg)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	rpn, err := shuntingYard(toks)
+	s.setAns(result{val: float64(n)})
+	fmt.Fprintln(s.out, "=", s.formatValue(float64(n)))
+	return nil
+}
+
+// runFile executa um ficheiro linha a linha na sessão dada, ignorando linhas
+// vazias e comentários (#). Os erros são mostrados com o número da linha; em
+// modo strict o primeiro erro interrompe o ficheiro.
+func runFile(s *Session, path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var first error
+		quit, failed := s.execStatements(line, func(err error) {
+			err = fmt.Errorf("%s:%d: %w", path, n, err)
+			if first == nil {
+				first = err
+			}
+			if !s.strict {
+				fmt.Fprintln(s.errOut, msg("error"), err)
+			}
+		})
+		if failed && s.strict {
+			return first
+		}
+		if quit {
+			break
+		}
 	}
-	return evalRPN(rpn, lastAns)
+	return sc.Err()
 }
 
-func printHelp() {
-	fmt.Println("Calculadora Go — exemplos:")
-	fmt.Println("  2+2*3")
-	fmt.Println("  (1+2)^3/9")
-	fmt.Println("  sqrt(2), log(100), ln(e), abs(-3.5)")
-	fmt.Println("  sin(pi/2), cos(0), tan(pi/4)")
-	fmt.Println("  max(3, 9), min(4, -2)")
-	fmt.Println("  Use ans para o último resultado, ex.: 1+ans")
-	fmt.Println("  Comandos: :quit para sair, :help para ajuda, :const para listar constantes, :func para listar funções")
+// printError mostra um erro em s.errOut; no modo interativo, se o erro tiver posição,
+// assinala-a com um ^ por baixo da linha escrita a seguir ao prompt "> ".
+func (s *Session) printError(line string, err error) {
+	var pe *posError
+	if s.interactive && errors.As(err, &pe) && pe.offset <= len(line) && !strings.HasPrefix(line, ":") {
+		fmt.Fprintln(s.errOut, strings.Repeat(" ", 2+utf8.RuneCountInString(line[:pe.offset]))+"^")
+	}
+	fmt.Fprintln(s.errOut, msg("error"), err)
 }
 
 func main() {
-	fmt.Println("Calculadora em Go — REPL (:help para ajuda)")
-	in := bufio.NewScanner(os.Stdin)
-	lastAns := 0.0
-	for {
-		fmt.Print("> ")
-		if !in.Scan() {
-			break
+	file := flag.String("file", "", "executa um ficheiro .calc e termina")
+	strict := flag.Bool("strict", false, "pára no primeiro erro de um ficheiro")
+	continueOnError := flag.Bool("continue-on-error", false, "numa linha com ;, continua depois de uma expressão com erro (por omissão no modo interativo)")
+	addr := flag.String("serve", "", "inicia a API HTTP no endereço dado, ex.: :8080")
+	grpcAddr := flag.String("grpc", "", "inicia o serviço gRPC de calculator.proto no endereço dado, ex.: :50051")
+	histFile := flag.String("history-file", "", "ficheiro de histórico (por omissão $CALC_HISTORY_FILE ou ~/.calc_history)")
+	histSize := flag.Int("history-size", defaultHistorySize, "número de expressões do histórico carregadas no arranque")
+	verbose := flag.Bool("verbose", false, "mostra cada passo da avaliação (como :verbose on)")
+	flag.Parse()
+
+	if *addr != "" || *grpcAddr != "" {
+		// com os dois, correm lado a lado; o primeiro erro termina o programa
+		errc := make(chan error, 2)
+		if *addr != "" {
+			fmt.Println(msg("serving"), *addr)
+			go func() { errc <- serve(*addr) }()
 		}
-		line := strings.TrimSpace(in.Text())
-		if line == "" {
-			continue
+		if *grpcAddr != "" {
+			fmt.Println(msg("grpc_serving"), *grpcAddr)
+			go func() { errc <- serveGRPC(*grpcAddr) }()
 		}
-		if strings.HasPrefix(line, ":") {
-			switch strings.ToLower(line) {
-			case ":quit", ":q", ":exit":
-				return
-			case ":help", ":h":
-				printHelp()
-			case ":const":
-				fmt.Println("Constantes:")
-				for k, v := range constants {
-					fmt.Printf("  %s = %.15g\n", k, v)
-				}
-			case ":func":
-				fmt.Println("Funções: sin, cos, tan, sqrt, log, ln, abs, floor, ceil, round, max(a,b), min(a,b)")
-			default:
-				fmt.Println("Comando desconhecido. Use :help")
+		fmt.Fprintln(os.Stderr, msg("error"), <-errc)
+		os.Exit(1)
+	}
+
+	s := newSession(os.Stdin, os.Stdout, os.Stderr)
+	s.strict = *strict
+	s.continueOnError = *continueOnError || (s.interactive && *file == "")
+	s.ctx.verbose = *verbose
+	if f, err := openLog(); err != nil {
+		fmt.Fprintf(s.errOut, msg("log_error")+"\n", err)
+	} else if f != nil {
+		s.log = f
+		defer func() {
+			if s.log != nil {
+				s.log.Close()
 			}
-			continue
+		}()
+	}
+	if *file != "" {
+		s.interactive = false
+		if err := runFile(s, *file); err != nil {
+			fmt.Fprintln(s.errOut, msg("error"), err)
+			os.Exit(1)
 		}
-		res, err := evalExpr(line, lastAns)
-		if err != nil {
-			fmt.Println("Erro:", err)
+		return
+	}
+
+	if s.interactive {
+		s.historyFile = historyPath(*histFile)
+		if s.historyFile != "" {
+			h, err := loadHistory(s.historyFile, *histSize)
+			if err != nil {
+				fmt.Fprintln(s.errOut, msg("error"), err)
+			}
+			s.history, s.historyLoaded = h, len(h)
+		}
+		defer func() {
+			if err := s.saveHistory(); err != nil {
+				fmt.Fprintln(s.errOut, msg("error"), err)
+			}
+		}()
+	}
+
+	s.repl()
+}
+
+// repl é o ciclo principal: lê linhas de s.in até ao fim da entrada ou a
+// :quit. A entrada e as saídas são as dadas a newSession, que podem ser
+// qualquer io.Reader e io.Writer (um pipe, um strings.Reader, um
+// bytes.Buffer), não só os.Stdin e os.Stdout.
+func (s *Session) repl() {
+	fmt.Fprintln(s.out, msg("banner"))
+	for {
+		fmt.Fprint(s.out, "> ")
+		if !s.in.Scan() {
+			return
+		}
+		line := strings.TrimSpace(s.in.Text())
+		if line == "" {
 			continue
 		}
-		lastAns = res
-		fmt.Printf("= %.15g\n", res)
+		s.addHistory(line)
+		quit, _ := s.execStatements(line, func(err error) { s.printError(line, err) })
+		if quit {
+			return
+		}
 	}
 }
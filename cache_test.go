@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// A cache não pode devolver resultados de um estado anterior: nem depois de
+// polyfit trocar o polinómio, nem quando uma variável muda sem passar por uma
+// atribuição (:range, os solvers).
+func TestCacheState(t *testing.T) {
+	ctx := &EvalContext{vars: map[string]float64{}, cache: ExprCache{}}
+	eval := func(expr string) float64 {
+		t.Helper()
+		rpn, err := compile(expr, ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		v, err := evalCached(rpn, ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		return v
+	}
+	ctx.poly = []float64{1, 2, 1}
+	if got := eval("polyeval(2)"); got != 9 {
+		t.Fatalf("polyeval(2) = %v, want 9", got)
+	}
+	eval("polyfit(0, 0, 1, 0, 1)")
+	if got := eval("polyeval(2)"); got != 0 {
+		t.Errorf("polyeval(2) depois de polyfit = %v, want 0", got)
+	}
+	ctx.vars["x"] = 2
+	if got := eval("x^2"); got != 4 {
+		t.Fatalf("x^2 = %v, want 4", got)
+	}
+	ctx.vars["x"] = 3
+	if got := eval("x^2"); got != 9 {
+		t.Errorf("x^2 com x = 3 = %v, want 9", got)
+	}
+}
+
+// Os mesmos tokens dão outro valor noutra unidade de ângulo: :rad, :deg e
+// :grad têm de limpar a cache.
+func TestCacheAngleUnit(t *testing.T) {
+	s := newSession(nil, io.Discard, io.Discard)
+	eval := func(expr string) float64 {
+		t.Helper()
+		r, err := s.eval(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		return r.val
+	}
+	for _, cmd := range []string{":cache on", ":rad"} {
+		if _, err := s.command(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := eval("sin(90)"); got == 1 {
+		t.Fatalf("sin(90) em radianos = %v", got)
+	}
+	for _, tc := range []struct {
+		cmd  string
+		want float64
+	}{
+		{":deg", 1},
+		{":grad", math.Sin(90 * math.Pi / 200)},
+		{":rad", math.Sin(90)},
+	} {
+		if _, err := s.command(tc.cmd); err != nil {
+			t.Fatal(err)
+		}
+		if got := eval("sin(90)"); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("sin(90) depois de %s = %v, want %v", tc.cmd, got, tc.want)
+		}
+	}
+}
+
+// benchmarkIdentity avalia sin(x)^2 + cos(x)^2 10000 vezes, com ou sem cache.
+func benchmarkIdentity(b *testing.B, cache ExprCache) {
+	ctx := &EvalContext{vars: map[string]float64{"x": 0.5}, cache: cache}
+	rpn, err := compile("sin(x)^2 + cos(x)^2", ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		for range 10000 {
+			if _, err := evalCached(rpn, ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkEvalUncached(b *testing.B) { benchmarkIdentity(b, nil) }
+
+func BenchmarkEvalCached(b *testing.B) { benchmarkIdentity(b, ExprCache{}) }
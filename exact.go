@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Modo :exact — as expressões racionais são avaliadas com big.Rat, sem a perda
+// de precisão do float64: inteiros acima de 2^53 e frações (1/3 + 1/6 = 1/2).
+// Quando a expressão sai dos racionais (sin, sqrt, pi, expoente fracionário,
+// ...) usa-se a avaliação normal em float64.
+
+// limites para não bloquear o REPL com números gigantes
+const (
+	maxExactBits      = 1 << 24
+	maxExactFactorial = 100000
+)
+
+// errNotExact indica que a expressão não tem resultado racional exato.
+var errNotExact = errors.New("not exact")
+
+// evalExact avalia a forma pós-fixa com racionais de precisão arbitrária.
+// Devolve errNotExact quando é preciso recorrer ao float64.
+func evalExact(rpn []token, ctx *EvalContext) (*big.Rat, error) {
+	var st []*big.Rat
+	pop := func(n int) []*big.Rat {
+		args := st[len(st)-n:]
+		st = st[:len(st)-n]
+		return args
+	}
+	for _, t := range rpn {
+		switch t.typ {
+		case tNumber:
+			n, ok := new(big.Rat).SetString(t.val)
+			if !ok {
+				return nil, errNotExact
+			}
+			st = append(st, n)
+		case tIdent:
+			var v float64
+			switch {
+			case t.val == "ans" && ctx.exactAns != nil:
+				st = append(st, new(big.Rat).Set(ctx.exactAns))
+				continue
+			case t.val == "ans":
+				v = ctx.lastAns
+			case ctx.varResults[t.val].exact != nil:
+				st = append(st, new(big.Rat).Set(ctx.varResults[t.val].exact))
+				continue
+			default:
+				var ok bool
+				if v, ok = ctx.vars[t.val]; !ok {
+					return nil, errNotExact // constantes não são racionais
+				}
+			}
+			// só os inteiros: 0.1 guardado em float64 já não é 1/10, e acima de
+			// 2^53 o float64 pode já vir arredondado (3^50 calculado sem :exact)
+			n, ok := floatToInt(v)
+			if !ok || math.Abs(v) > 1<<53 {
+				return nil, errNotExact
+			}
+			st = append(st, new(big.Rat).SetInt(n))
+		case tOp:
+			op := ops[t.val]
+			if op.unary {
+				if len(st) < 1 {
+					return nil, errAt(t.offset, errors.New(msg("unary_no_operand")))
+				}
+				switch t.val {
+				case "u-":
+					st[len(st)-1].Neg(st[len(st)-1])
+				case "%":
+					st[len(st)-1].Quo(st[len(st)-1], big.NewRat(100, 1))
+				}
+				continue
+			}
+			if len(st) < 2 {
+				return nil, errAt(t.offset, errors.New(msg("binary_few_operands")))
+			}
+			ab := pop(2)
+			res, err := exactBinary(t, ab[0], ab[1], ctx)
+			if err != nil {
+				return nil, err
+			}
+			st = append(st, res)
+		case tFunc:
+			nargs, err := argCount(t)
+			if err != nil {
+				return nil, err
+			}
+			if len(st) < nargs {
+				return nil, errAt(t.offset, fmt.Errorf(msg("func_few_args"), t.val))
+			}
+			res, err := exactFunc(t.val, pop(nargs))
+			if err != nil {
+				return nil, err
+			}
+			st = append(st, res)
+		}
+	}
+	if len(st) != 1 {
+		return nil, errors.New(msg("invalid_expr"))
+	}
+	return st[0], nil
+}
+
+func exactBinary(t token, a, b *big.Rat, ctx *EvalContext) (*big.Rat, error) {
+	res := new(big.Rat)
+	switch t.val {
+	case "+":
+		return res.Add(a, b), nil
+	case "-":
+		return res.Sub(a, b), nil
+	case "*", "of":
+		return res.Mul(a, b), nil
+	case "|":
+		if !a.IsInt() || !b.IsInt() {
+			return nil, errNotExact
+		}
+		return res.SetInt(new(big.Int).Or(a.Num(), b.Num())), nil
+	case "/", "//":
+		if b.Sign() == 0 {
+			af, _ := a.Float64()
+			return nil, errAt(t.offset, errorFormatter(ctx).EvalError(t.val, []float64{af, 0}))
+		}
+		res.Quo(a, b)
+		if t.val == "//" || ctx.intMode {
+			return res.SetInt(ratFloor(res)), nil
+		}
+		return res, nil
+	case "^":
+		if !b.IsInt() || !b.Num().IsInt64() {
+			return nil, errNotExact
+		}
+		k := b.Num().Int64()
+		if k < 0 && a.Sign() == 0 {
+			return nil, errNotExact // 0^-k é infinito
+		}
+		if int64(a.Num().BitLen()+a.Denom().BitLen())*max(k, -k) > maxExactBits {
+			return nil, errAt(t.offset, errors.New(msg("exact_too_big")))
+		}
+		e := big.NewInt(max(k, -k))
+		res.SetFrac(new(big.Int).Exp(a.Num(), e, nil), new(big.Int).Exp(a.Denom(), e, nil))
+		if k < 0 {
+			res.Inv(res)
+		}
+		return res, nil
+	}
+	return nil, errNotExact
+}
+
+// ratFloor devolve o maior inteiro <= x.
+func ratFloor(x *big.Rat) *big.Int {
+	// o denominador é sempre positivo, e com divisor positivo a divisão
+	// euclidiana de big.Int arredonda para baixo
+	q, _ := new(big.Int).DivMod(x.Num(), x.Denom(), new(big.Int))
+	return q
+}
+
+// exactFunc calcula as funções com resultado racional; as que só fazem
+// sentido para inteiros passam a exactIntFunc.
+func exactFunc(name string, a []*big.Rat) (*big.Rat, error) {
+	res := new(big.Rat)
+	switch name {
+	case "abs":
+		return res.Abs(a[0]), nil
+	case "floor":
+		return res.SetInt(ratFloor(a[0])), nil
+	case "ceil":
+		return res.SetInt(ratFloor(res.Neg(a[0]))).Neg(res), nil
+	case "round":
+		// metades para longe do zero, como math.Round
+		res.Abs(a[0])
+		res.SetInt(ratFloor(res.Add(res, big.NewRat(1, 2))))
+		if a[0].Sign() < 0 {
+			res.Neg(res)
+		}
+		return res, nil
+	case "max":
+		if a[0].Cmp(a[1]) > 0 {
+			return a[0], nil
+		}
+		return a[1], nil
+	case "min":
+		if a[0].Cmp(a[1]) < 0 {
+			return a[0], nil
+		}
+		return a[1], nil
+	}
+	ints := make([]*big.Int, len(a))
+	for i, x := range a {
+		if !x.IsInt() {
+			return nil, errNotExact
+		}
+		ints[i] = x.Num()
+	}
+	n, err := exactIntFunc(name, ints)
+	if err != nil {
+		return nil, err
+	}
+	return res.SetInt(n), nil
+}
+
+func exactIntFunc(name string, a []*big.Int) (*big.Int, error) {
+	res := new(big.Int)
+	switch name {
+	case "isqrt":
+		if a[0].Sign() < 0 {
+			return nil, fmt.Errorf(msg("needs_nonneg_int"), name)
+		}
+		return res.Sqrt(a[0]), nil
+	case "factorial":
+		if a[0].Sign() < 0 {
+			return nil, fmt.Errorf(msg("needs_nonneg_int"), name)
+		}
+		if !a[0].IsInt64() || a[0].Int64() > maxExactFactorial {
+			return nil, errors.New(msg("exact_too_big"))
+		}
+		return res.MulRange(1, a[0].Int64()), nil
+	case "fib":
+		if a[0].Sign() < 0 {
+			return nil, fmt.Errorf(msg("needs_nonneg_int"), name)
+		}
+		// F(n) tem cerca de 0.695·n bits
+		if !a[0].IsInt64() || a[0].Int64() > maxExactBits/7*10 {
+			return nil, errors.New(msg("exact_too_big"))
+		}
+		f, _ := fibPairExact(a[0].Int64())
+		return f, nil
+	case "primorial":
+		if a[0].Sign() < 0 || !a[0].IsInt64() || a[0].Int64() > maxExactFactorial {
+			return nil, errNotExact // o float64 dá o erro certo
+		}
+		res.SetInt64(1)
+		for _, p := range sieve(int(a[0].Int64())) {
+			res.Mul(res, big.NewInt(int64(p)))
+		}
+		return res, nil
+	case "divmod_q", "divmod_r":
+		if a[1].Sign() == 0 {
+			return nil, errors.New(msg("division_by_zero"))
+		}
+		m := new(big.Int)
+		floorDivMod(a[0], a[1], res, m)
+		if name == "divmod_r" {
+			return m, nil
+		}
+		return res, nil
+	}
+	return nil, errNotExact
+}
+
+// fibPairExact é fibPair com big.Int: devolve F(n) e F(n+1).
+func fibPairExact(n int64) (*big.Int, *big.Int) {
+	if n == 0 {
+		return big.NewInt(0), big.NewInt(1)
+	}
+	a, b := fibPairExact(n / 2)
+	c := new(big.Int).Lsh(b, 1)
+	c.Mul(a, c.Sub(c, a))
+	d := new(big.Int).Mul(a, a)
+	d.Add(d, new(big.Int).Mul(b, b))
+	if n%2 == 0 {
+		return c, d
+	}
+	return d, c.Add(c, d)
+}
+
+// floorDivMod calcula q = floor(a/b) e m = a - b*q, como divmod_q e divmod_r.
+func floorDivMod(a, b, q, m *big.Int) {
+	q.DivMod(a, b, m) // divisão euclidiana: 0 <= m < |b|
+	if m.Sign() != 0 && b.Sign() < 0 {
+		q.Sub(q, big.NewInt(1))
+		m.Add(m, b)
+	}
+}
+
+// floatToInt converte um float64 inteiro para big.Int sem perda.
+func floatToInt(v float64) (*big.Int, bool) {
+	if math.IsInf(v, 0) || math.IsNaN(v) || v != math.Trunc(v) {
+		return nil, false
+	}
+	n, _ := new(big.Float).SetFloat64(v).Int(nil)
+	return n, true
+}
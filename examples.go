@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// :example tema — exemplos resolvidos, avaliados na hora, como documentação
+// interativa.
+
+// localized é um texto em várias línguas, indexado como messages.
+type localized map[string]string
+
+func (l localized) String() string {
+	if s, ok := l[lang]; ok {
+		return s
+	}
+	return l["pt"]
+}
+
+// Example é uma expressão de exemplo e o que ela mostra.
+type Example struct {
+	expr        string
+	explanation localized
+}
+
+var examples = map[string][]Example{
+	"trig": {
+		{expr: "sin(pi/6)", explanation: localized{"pt": "seno de 30°", "en": "sine of 30°"}},
+		{expr: "cos(pi/3)", explanation: localized{"pt": "cosseno de 60°", "en": "cosine of 60°"}},
+		{expr: "tan(pi/4)", explanation: localized{"pt": "tangente de 45°", "en": "tangent of 45°"}},
+		{expr: "sin(1)^2 + cos(1)^2", explanation: localized{"pt": "identidade fundamental, para qualquer x", "en": "Pythagorean identity, for any x"}},
+		{expr: "45*pi/180", explanation: localized{"pt": "45° em radianos", "en": "45° in radians"}},
+		{expr: "angle2(1, 0, 0, 1)", explanation: localized{"pt": "ângulo entre os eixos x e y (pi/2)", "en": "angle between the x and y axes (pi/2)"}},
+	},
+	"finance": {
+		{expr: "1000*(1 + 0.05)^10", explanation: localized{"pt": "1000 € a 5% ao ano, juro composto durante 10 anos", "en": "1000 at 5% a year, compounded for 10 years"}},
+		{expr: "1000*(1 + 0.05/12)^(12*10)", explanation: localized{"pt": "o mesmo, capitalizado mensalmente", "en": "the same, compounded monthly"}},
+		{expr: "1000*e^(0.05*10)", explanation: localized{"pt": "o mesmo, capitalização contínua", "en": "the same, compounded continuously"}},
+		{expr: "100000*(0.04/12)/(1 - (1 + 0.04/12)^-360)", explanation: localized{"pt": "prestação mensal de um empréstimo de 100000 a 30 anos, taxa de 4%", "en": "monthly payment on a 100000 loan over 30 years at 4%"}},
+		{expr: "1000/(1 + 0.03)^5", explanation: localized{"pt": "valor atual de 1000 recebidos daqui a 5 anos, a 3%", "en": "present value of 1000 received in 5 years, at 3%"}},
+		{expr: "ln(2)/ln(1 + 0.07)", explanation: localized{"pt": "anos para duplicar um capital a 7% (regra dos 72: ~10,3)", "en": "years to double money at 7% (rule of 72: ~10.3)"}},
+	},
+	"stats": {
+		{expr: "normal_cdf(1.96, 0, 1) - normal_cdf(-1.96, 0, 1)", explanation: localized{"pt": "probabilidade de |Z| < 1,96 (95%)", "en": "probability that |Z| < 1.96 (95%)"}},
+		{expr: "qnorm(0.975)", explanation: localized{"pt": "quantil de 97,5% da normal padrão", "en": "97.5% quantile of the standard normal"}},
+		{expr: "binom_pmf(3, 10, 0.5)", explanation: localized{"pt": "3 caras em 10 lançamentos de uma moeda", "en": "3 heads in 10 coin tosses"}},
+		{expr: "binom_cdf(3, 10, 0.5)", explanation: localized{"pt": "no máximo 3 caras em 10 lançamentos", "en": "at most 3 heads in 10 tosses"}},
+		{expr: "poisson_pmf(2, 3)", explanation: localized{"pt": "2 ocorrências quando a média é 3", "en": "2 events when the mean is 3"}},
+		{expr: "t_ppf(0.975, 10)", explanation: localized{"pt": "valor crítico do t de Student, 95% bilateral, 10 g.l.", "en": "Student's t critical value, two-sided 95%, 10 d.f."}},
+		{expr: "chi2_ppf(0.95, 3)", explanation: localized{"pt": "valor crítico do qui-quadrado a 5%, 3 g.l.", "en": "chi-squared critical value at 5%, 3 d.f."}},
+	},
+	"numbers": {
+		{expr: "factorial(10)", explanation: localized{"pt": "10! = 1·2·...·10", "en": "10! = 1·2·...·10"}},
+		{expr: "fib(30)", explanation: localized{"pt": "30.º número de Fibonacci", "en": "30th Fibonacci number"}},
+		{expr: "catalan(10)", explanation: localized{"pt": "árvores binárias com 10 nós", "en": "binary trees with 10 nodes"}},
+		{expr: "bigomega(360)", explanation: localized{"pt": "360 = 2³·3²·5 tem 6 fatores primos", "en": "360 = 2³·3²·5 has 6 prime factors"}},
+		{expr: "mobius(30)", explanation: localized{"pt": "30 = 2·3·5, número ímpar de primos distintos", "en": "30 = 2·3·5, odd number of distinct primes"}},
+		{expr: "digitsum(2^20)", explanation: localized{"pt": "soma dos dígitos de 1048576", "en": "digit sum of 1048576"}},
+		{expr: "isqrt(1000)", explanation: localized{"pt": "maior inteiro cujo quadrado não passa de 1000", "en": "largest integer whose square is at most 1000"}},
+	},
+	"algebra": {
+		{expr: "poly(2, -6, 11, -6, 1)", explanation: localized{"pt": "(x-1)(x-2)(x-3) em x = 2: é uma raiz", "en": "(x-1)(x-2)(x-3) at x = 2: it is a root"}},
+		{expr: "polyderiv(2, -6, 11, -6, 1)", explanation: localized{"pt": "derivada do mesmo polinómio em x = 2", "en": "derivative of the same polynomial at x = 2"}},
+		{expr: "det2(3, 8, 4, 6)", explanation: localized{"pt": "determinante de [[3,8],[4,6]]", "en": "determinant of [[3,8],[4,6]]"}},
+		{expr: "dot3(1, 2, 3, 4, 5, 6)", explanation: localized{"pt": "produto interno de (1,2,3) e (4,5,6)", "en": "dot product of (1,2,3) and (4,5,6)"}},
+		{expr: "sigma(k^2, k, 1, 10)", explanation: localized{"pt": "soma dos quadrados de 1 a 10, n(n+1)(2n+1)/6", "en": "sum of squares from 1 to 10, n(n+1)(2n+1)/6"}},
+		{expr: "cf_convergent(pi, 4)", explanation: localized{"pt": "355/113, a melhor aproximação racional de pi", "en": "355/113, the best rational approximation of pi"}},
+	},
+}
+
+// exampleTopics devolve os temas de :example, ordenados.
+func exampleTopics() []string {
+	topics := make([]string, 0, len(examples))
+	for t := range examples {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// printExamples avalia e mostra os exemplos de um tema, alinhados em colunas:
+// expressão, resultado e explicação.
+func (s *Session) printExamples(topic string) error {
+	list, ok := examples[topic]
+	if !ok {
+		return fmt.Errorf(msg("usage_example"), strings.Join(exampleTopics(), "|"))
+	}
+	results := make([]string, len(list))
+	we, wr := 0, 0
+	for i, ex := range list {
+		v, err := evalExpr(ex.expr, &EvalContext{vars: map[string]float64{}})
+		if err != nil {
+			return err
+		}
+		results[i] = s.formatValue(v)
+		we, wr = max(we, len(ex.expr)), max(wr, len(results[i]))
+	}
+	for i, ex := range list {
+		fmt.Fprintf(s.out, "  %-*s = %-*s  %s\n", we, ex.expr, wr, results[i], ex.explanation)
+	}
+	return nil
+}
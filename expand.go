@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// :expand (a+b)^n — binómio de Newton: escreve a soma de C(n,k)·a^(n-k)·b^k
+// para k de 0 a n. a e b são um número ou um nome; com (a-b)^n os sinais
+// alternam. Se a expressão não tiver esta forma, é só avaliada.
+
+// maxExpandPower é o maior n que :expand escreve por extenso.
+const maxExpandPower = 100
+
+// binomialPattern reconhece ( a ± b ) ^ n e devolve as partes.
+func binomialPattern(toks []token) (a, b token, minus bool, n int, ok bool) {
+	if len(toks) != 7 || toks[0].typ != tLParen || toks[2].typ != tOp || toks[4].typ != tRParen ||
+		toks[5].typ != tOp || toks[5].val != "^" || toks[6].typ != tNumber {
+		return
+	}
+	a, b = toks[1], toks[3]
+	if (a.typ != tNumber && a.typ != tIdent) || (b.typ != tNumber && b.typ != tIdent) {
+		return
+	}
+	if toks[2].val != "+" && toks[2].val != "-" {
+		return
+	}
+	n, err := strconv.Atoi(toks[6].val)
+	if err != nil || n < 0 || n > maxExpandPower {
+		return
+	}
+	return a, b, toks[2].val == "-", n, true
+}
+
+// power escreve x^e, omitindo o expoente 1; vazio se e = 0.
+func power(x string, e int) string {
+	switch e {
+	case 0:
+		return ""
+	case 1:
+		return x
+	}
+	return x + "^" + strconv.Itoa(e)
+}
+
+// binomialExpansion escreve a expansão de (a ± b)^n: a^3 + 3a^2·b + ...
+func binomialExpansion(a, b token, minus bool, n int) string {
+	var out strings.Builder
+	for k := 0; k <= n; k++ {
+		coef := new(big.Int).Binomial(int64(n), int64(k)).String()
+		var factors []string
+		for _, f := range []string{power(a.val, n-k), power(b.val, k)} {
+			if f != "" {
+				factors = append(factors, f)
+			}
+		}
+		term := strings.Join(factors, "·")
+		switch {
+		case term == "":
+			term = coef
+		case coef != "1" && isASCIIDigit(term[0]):
+			term = coef + "·" + term // 3·2^2, não 32^2
+		case coef != "1":
+			term = coef + term
+		}
+		switch {
+		case k == 0:
+			out.WriteString(term)
+		case minus && k%2 == 1:
+			out.WriteString(" - " + term)
+		default:
+			out.WriteString(" + " + term)
+		}
+	}
+	return out.String()
+}
+
+// expand trata ":expand expr".
+func (s *Session) expand(arg string) error {
+	toks, err := tokenize(arg, s.ctx)
+	if err != nil {
+		return err
+	}
+	a, b, minus, n, ok := binomialPattern(toks)
+	if ok {
+		fmt.Fprintln(s.out, binomialExpansion(a, b, minus, n))
+		if a.typ != tNumber || b.typ != tNumber {
+			return nil
+		}
+	}
+	results, err := s.evalAll(arg)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		fmt.Fprintln(s.out, "=", s.formatResult(r))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// isqrt tem de coincidir com big.Int.Sqrt onde o float64 já não chega:
+// quadrados perfeitos e os seus vizinhos à volta de 2^53 e até ao maior int64.
+func TestIsqrt(t *testing.T) {
+	var ns []int64
+	for _, k := range []int64{1, 2, 3, 12, 1e6, 94906265, 94906266, 1e9, 3037000499} {
+		ns = append(ns, k*k-1, k*k, k*k+1)
+	}
+	for d := int64(-3); d <= 3; d++ {
+		ns = append(ns, 1<<53+d, 1<<62+d)
+	}
+	ns = append(ns, 0, 1e18, math.MaxInt64-1, math.MaxInt64)
+	for _, n := range ns {
+		want := new(big.Int).Sqrt(big.NewInt(n)).Int64()
+		if got := isqrt(n); got != want {
+			t.Errorf("isqrt(%d) = %d, want %d", n, got, want)
+		}
+	}
+	// pela calculadora: só os argumentos que o float64 representa exatamente
+	for _, tc := range []struct {
+		expr string
+		want float64
+	}{
+		{"isqrt(144)", 12},
+		{"isqrt(145)", 12},
+		{"isqrt(10^18)", 1e9},
+		{"isqrt(2^53)", 94906265},
+		{"isqrt(94906265^2)", 94906265},
+		{"isqrt(94906265^2 - 1)", 94906264},
+	} {
+		got, err := evalExpr(tc.expr, &EvalContext{vars: map[string]float64{}})
+		if err != nil || got != tc.want {
+			t.Errorf("%s = %v, %v; want %v", tc.expr, got, err, tc.want)
+		}
+	}
+	for _, expr := range []string{"isqrt(-1)", "isqrt(2.5)"} {
+		if _, err := evalExpr(expr, &EvalContext{vars: map[string]float64{}}); err == nil {
+			t.Errorf("%s devia dar erro", expr)
+		}
+	}
+}
+
+// floorm e ceilm só aceitam múltiplos positivos: m = 0 e m < 0 são erros,
+// seja x positivo ou negativo.
+func TestFloorCeilMultipleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"floorm(2.7, 0)", "ceilm(2.7, 0)",
+		"floorm(2.7, -0.5)", "ceilm(2.3, -0.25)",
+		"floorm(-2.7, -0.5)", "ceilm(-2.3, -0.25)",
+	} {
+		_, err := evalExpr(expr, &EvalContext{vars: map[string]float64{}})
+		if err == nil || err.Error() != msg("multiple_positive") {
+			t.Errorf("%s: erro %v, want %q", expr, err, msg("multiple_positive"))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// :game — adivinhar um número entre 1 e 100. Os palpites podem ser
+// expressões (2^6, sqrt(2500)), avaliadas no contexto da sessão sem o
+// alterar; :quit desiste e volta ao REPL.
+
+const gameMax = 100
+
+func (s *Session) playGame() {
+	secret := rand.Intn(gameMax) + 1
+	fmt.Fprintf(s.out, msg("game_start")+"\n", gameMax)
+	for tries := 1; ; {
+		fmt.Fprint(s.out, "? ")
+		if !s.in.Scan() {
+			return
+		}
+		line := strings.TrimSpace(s.in.Text())
+		switch strings.ToLower(line) {
+		case "":
+			continue
+		case ":quit", ":q", ":exit":
+			fmt.Fprintf(s.out, msg("game_quit")+"\n", secret)
+			return
+		}
+		v, err := evalExpr(line, s.ctx)
+		if err != nil {
+			fmt.Fprintln(s.errOut, msg("error"), err)
+			continue
+		}
+		guess := int(math.Round(v))
+		switch {
+		case guess < secret:
+			fmt.Fprintln(s.out, msg("game_higher"))
+		case guess > secret:
+			fmt.Fprintln(s.out, msg("game_lower"))
+		default:
+			fmt.Fprintf(s.out, msg("game_correct")+"\n", tries)
+			return
+		}
+		tries++
+	}
+}
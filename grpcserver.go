@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serviço gRPC de calculator.proto (--grpc): Eval e EvalStream sobre HTTP/2
+// sem TLS, com a avaliação sem estado da API HTTP (evalStateless). Não há
+// go.mod para google.golang.org/grpc nem stubs gerados, por isso o protocolo
+// faz-se aqui com a biblioteca padrão: cada mensagem vai num frame (1 byte de
+// compressão e 4 de comprimento) e o estado final nos trailers grpc-status e
+// grpc-message. As duas mensagens protobuf são pequenas e codificam-se à mão.
+
+const (
+	grpcService = "/calculator.Calculator/"
+	// grpcMessageTimeout é o tempo máximo à espera da mensagem seguinte de um
+	// EvalStream; o stream em si pode durar uma sessão inteira
+	grpcMessageTimeout = 5 * time.Minute
+)
+
+// Códigos de estado gRPC usados pelo servidor.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// Tipos de campo da codificação protobuf.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// grpcStatus é um erro com código gRPC, enviado nos trailers.
+type grpcStatus struct {
+	code int
+	msg  string
+}
+
+func (s grpcStatus) Error() string { return s.msg }
+
+// grpcEvalRequest e grpcEvalResponse são EvalRequest e EvalResponse.
+type grpcEvalRequest struct {
+	expr      string
+	variables map[string]float64
+}
+
+type grpcEvalResponse struct {
+	result    float64
+	err       string
+	rpnTokens []string
+}
+
+// protoField é um campo lido de uma mensagem protobuf.
+type protoField struct {
+	num  int
+	typ  int
+	v    uint64 // valor dos campos varint, fixed64 e fixed32
+	data []byte // conteúdo dos campos delimitados (strings, mensagens)
+}
+
+// nextField lê o primeiro campo de b e devolve o resto.
+func nextField(b []byte) (f protoField, rest []byte, err error) {
+	bad := grpcStatus{grpcInvalidArgument, msg("grpc_bad_message")}
+	key, n := binary.Uvarint(b)
+	if n <= 0 {
+		return f, nil, bad
+	}
+	b = b[n:]
+	f.num, f.typ = int(key>>3), int(key&7)
+	switch f.typ {
+	case wireVarint:
+		if f.v, n = binary.Uvarint(b); n <= 0 {
+			return f, nil, bad
+		}
+		return f, b[n:], nil
+	case wireFixed64:
+		if len(b) < 8 {
+			return f, nil, bad
+		}
+		return protoField{f.num, f.typ, binary.LittleEndian.Uint64(b), nil}, b[8:], nil
+	case wireFixed32:
+		if len(b) < 4 {
+			return f, nil, bad
+		}
+		return protoField{f.num, f.typ, uint64(binary.LittleEndian.Uint32(b)), nil}, b[4:], nil
+	case wireBytes:
+		l, n := binary.Uvarint(b)
+		if n <= 0 || l > uint64(len(b)-n) {
+			return f, nil, bad
+		}
+		b = b[n:]
+		f.data = b[:l]
+		return f, b[l:], nil
+	}
+	return f, nil, bad
+}
+
+// decodeEvalRequest lê um EvalRequest. Os campos desconhecidos ignoram-se,
+// como manda o protobuf; variables é um map<string, double>, ou seja,
+// mensagens repetidas com a chave no campo 1 e o valor no campo 2.
+func decodeEvalRequest(b []byte) (grpcEvalRequest, error) {
+	req := grpcEvalRequest{variables: map[string]float64{}}
+	for len(b) > 0 {
+		f, rest, err := nextField(b)
+		if err != nil {
+			return req, err
+		}
+		b = rest
+		switch {
+		case f.num == 1 && f.typ == wireBytes:
+			req.expr = string(f.data)
+		case f.num == 2 && f.typ == wireBytes:
+			var key string
+			var val float64
+			for e := f.data; len(e) > 0; {
+				ef, rest, err := nextField(e)
+				if err != nil {
+					return req, err
+				}
+				e = rest
+				switch {
+				case ef.num == 1 && ef.typ == wireBytes:
+					key = string(ef.data)
+				case ef.num == 2 && ef.typ == wireFixed64:
+					val = math.Float64frombits(ef.v)
+				}
+			}
+			req.variables[key] = val
+		}
+	}
+	return req, nil
+}
+
+// appendProtoString acrescenta a b um campo string.
+func appendProtoString(b []byte, num int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// marshal codifica o EvalResponse; como em proto3, os valores por omissão
+// (result 0, error vazio) não se escrevem.
+func (r grpcEvalResponse) marshal() []byte {
+	var b []byte
+	if bits := math.Float64bits(r.result); bits != 0 {
+		b = binary.AppendUvarint(b, 1<<3|wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, bits)
+	}
+	if r.err != "" {
+		b = appendProtoString(b, 2, r.err)
+	}
+	for _, t := range r.rpnTokens {
+		b = appendProtoString(b, 3, t)
+	}
+	return b
+}
+
+// readGRPCMessage lê uma mensagem do corpo do pedido; io.EOF quando o cliente
+// fechou o stream entre mensagens. As mensagens comprimidas não são aceites.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, grpcStatus{grpcInvalidArgument, msg("grpc_bad_message")}
+	}
+	if hdr[0] != 0 {
+		return nil, grpcStatus{grpcUnimplemented, msg("grpc_compressed")}
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxServeBody {
+		return nil, grpcStatus{grpcResourceExhausted, fmt.Sprintf(msg("grpc_too_large"), n, maxServeBody)}
+	}
+	m := make([]byte, n)
+	if _, err := io.ReadFull(r, m); err != nil {
+		return nil, grpcStatus{grpcInvalidArgument, msg("grpc_bad_message")}
+	}
+	return m, nil
+}
+
+// writeGRPCMessage escreve m num frame sem compressão.
+func writeGRPCMessage(w io.Writer, m []byte) error {
+	frame := make([]byte, 5, 5+len(m))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(m)))
+	_, err := w.Write(append(frame, m...))
+	return err
+}
+
+// evalGRPCRequest avalia um EvalRequest. Os erros da expressão vão no campo
+// error da resposta, com rpn_tokens preenchido se a expressão compilou.
+func evalGRPCRequest(req grpcEvalRequest) grpcEvalResponse {
+	res, rpn, _, err := evalStateless(req.expr, req.variables)
+	var resp grpcEvalResponse
+	for _, t := range rpn {
+		resp.rpnTokens = append(resp.rpnTokens, t.val)
+	}
+	if err != nil {
+		resp.err = err.Error()
+		return resp
+	}
+	resp.result = res
+	return resp
+}
+
+// handleGRPC atende os dois métodos do serviço. Eval lê um pedido e responde
+// uma vez; EvalStream responde a cada pedido logo que chega, até o cliente
+// fechar o seu lado. Cada pedido é avaliado sozinho, como na API HTTP.
+func handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	method, _ := strings.CutPrefix(r.URL.Path, grpcService)
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	var err error
+	switch method {
+	case "Eval":
+		err = serveEvalRPC(w, r.Body, rc, false)
+	case "EvalStream":
+		err = serveEvalRPC(w, r.Body, rc, true)
+	default:
+		err = grpcStatus{grpcUnimplemented, fmt.Sprintf(msg("grpc_unknown_method"), r.URL.Path)}
+	}
+	setGRPCStatus(w, err)
+}
+
+func serveEvalRPC(w http.ResponseWriter, body io.Reader, rc *http.ResponseController, stream bool) error {
+	for {
+		rc.SetReadDeadline(time.Now().Add(grpcMessageTimeout))
+		m, err := readGRPCMessage(body)
+		if err == io.EOF {
+			if stream {
+				return nil
+			}
+			return grpcStatus{grpcInvalidArgument, msg("grpc_no_request")}
+		}
+		if err != nil {
+			return err
+		}
+		req, err := decodeEvalRequest(m)
+		if err != nil {
+			return err
+		}
+		if err := writeGRPCMessage(w, evalGRPCRequest(req).marshal()); err != nil {
+			return err
+		}
+		if !stream {
+			return nil
+		}
+		rc.Flush()
+	}
+}
+
+// setGRPCStatus põe o estado final nos trailers; grpc-message vai em
+// percent-encoding, como pede o protocolo.
+func setGRPCStatus(w http.ResponseWriter, err error) {
+	st := grpcStatus{code: grpcOK}
+	if err != nil && !errors.As(err, &st) {
+		st = grpcStatus{grpcInternal, err.Error()}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(st.code))
+	if st.msg != "" {
+		var b strings.Builder
+		for i := 0; i < len(st.msg); i++ {
+			if c := st.msg[i]; c < ' ' || c > '~' || c == '%' {
+				fmt.Fprintf(&b, "%%%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+		w.Header().Set("Grpc-Message", b.String())
+	}
+}
+
+// serveGRPC inicia o serviço gRPC no endereço dado, ex.: ":50051", em HTTP/2
+// sem TLS (o que os clientes gRPC usam com plaintext/insecure).
+func serveGRPC(addr string) error {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(handleGRPC),
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
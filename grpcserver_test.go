@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newGRPCServer inicia handleGRPC em HTTP/2 sem TLS e devolve um cliente que
+// fala HTTP/2 diretamente, como um cliente gRPC em modo plaintext.
+func newGRPCServer(t *testing.T) (*httptest.Server, *http.Client) {
+	t.Helper()
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(handleGRPC))
+	srv.Config.Protocols = &protocols
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+// marshalEvalRequest codifica um EvalRequest, do lado do cliente.
+func marshalEvalRequest(expr string, vars map[string]float64) []byte {
+	b := appendProtoString(nil, 1, expr)
+	for k, v := range vars {
+		entry := appendProtoString(nil, 1, k)
+		entry = binary.AppendUvarint(entry, 2<<3|wireFixed64)
+		entry = binary.LittleEndian.AppendUint64(entry, math.Float64bits(v))
+		b = binary.AppendUvarint(b, 2<<3|wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(entry)))
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// decodeEvalResponse lê um EvalResponse, do lado do cliente.
+func decodeEvalResponse(t *testing.T, b []byte) grpcEvalResponse {
+	t.Helper()
+	var r grpcEvalResponse
+	for len(b) > 0 {
+		f, rest, err := nextField(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = rest
+		switch f.num {
+		case 1:
+			r.result = math.Float64frombits(f.v)
+		case 2:
+			r.err = string(f.data)
+		case 3:
+			r.rpnTokens = append(r.rpnTokens, string(f.data))
+		}
+	}
+	return r
+}
+
+// grpcFrame devolve m num frame gRPC.
+func grpcFrame(m []byte) []byte {
+	var b bytes.Buffer
+	writeGRPCMessage(&b, m)
+	return b.Bytes()
+}
+
+// callGRPC faz um pedido com o corpo dado e devolve a resposta já lida
+// (mensagens e trailers).
+func callGRPC(t *testing.T, client *http.Client, url, method string, body []byte) (*http.Response, [][]byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+grpcService+method, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var msgs [][]byte
+	for {
+		m, err := readGRPCMessage(resp.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+	return resp, msgs
+}
+
+func TestGRPCEval(t *testing.T) {
+	srv, client := newGRPCServer(t)
+	tests := []struct {
+		expr   string
+		vars   map[string]float64
+		result float64
+		rpn    []string
+		err    string
+	}{
+		{"2+3*4", nil, 14, []string{"2", "3", "4", "*", "+"}, ""},
+		{"x^2 + y", map[string]float64{"X": 3, "y": 0.5}, 9.5, []string{"x", "2", "^", "y", "+"}, ""},
+		{"max(1, 2)", nil, 2, []string{"1", "2", "max"}, ""},
+		{"1/0", nil, 0, []string{"1", "0", "/"}, msg("division_by_zero")},
+		{"2+", nil, 0, []string{"2", "+"}, msg("binary_few_operands")},
+		{"(1+2", nil, 0, nil, msg("unbalanced_parens")},
+		{"sigma(sigma(k, k, 1, 1e6), j, 1, 1e6)", nil, 0, []string{"1", "1e6", "sigma"}, "1000000"},
+	}
+	for _, tc := range tests {
+		resp, msgs := callGRPC(t, client, srv.URL, "Eval", grpcFrame(marshalEvalRequest(tc.expr, tc.vars)))
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("HTTP/%d, want HTTP/2", resp.ProtoMajor)
+		}
+		if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+			t.Errorf("%s: grpc-status %q, want 0", tc.expr, got)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("%s: %d respostas, want 1", tc.expr, len(msgs))
+		}
+		r := decodeEvalResponse(t, msgs[0])
+		if r.result != tc.result || !slices.Equal(r.rpnTokens, tc.rpn) {
+			t.Errorf("%s = %v %q, want %v %q", tc.expr, r.result, r.rpnTokens, tc.result, tc.rpn)
+		}
+		if tc.err == "" && r.err != "" || !strings.Contains(r.err, tc.err) {
+			t.Errorf("%s: erro %q, want %q", tc.expr, r.err, tc.err)
+		}
+	}
+}
+
+// EvalStream responde a cada mensagem antes de o cliente mandar a seguinte,
+// e cada mensagem é avaliada sem o estado das anteriores.
+func TestGRPCEvalStream(t *testing.T) {
+	srv, client := newGRPCServer(t)
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+grpcService+"EvalStream", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	steps := []struct {
+		expr   string
+		vars   map[string]float64
+		result float64
+		err    string
+	}{
+		{"x * 2", map[string]float64{"x": 21}, 42, ""},
+		{"x", nil, 0, "x"},
+		{"ans + 1", nil, 1, ""},
+	}
+	for _, st := range steps {
+		pw.Write(grpcFrame(marshalEvalRequest(st.expr, st.vars)))
+		m, err := readGRPCMessage(resp.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", st.expr, err)
+		}
+		r := decodeEvalResponse(t, m)
+		if r.result != st.result || (st.err == "") != (r.err == "") || !strings.Contains(r.err, st.err) {
+			t.Errorf("%s = %v, erro %q; want %v, erro com %q", st.expr, r.result, r.err, st.result, st.err)
+		}
+	}
+	pw.Close()
+	if _, err := readGRPCMessage(resp.Body); err != io.EOF {
+		t.Fatalf("depois de fechar o stream: %v, want EOF", err)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("grpc-status %q, want 0", got)
+	}
+}
+
+// Os erros do protocolo vão no grpc-status, e não no campo error.
+func TestGRPCStatus(t *testing.T) {
+	srv, client := newGRPCServer(t)
+	req := grpcFrame(marshalEvalRequest("1+1", nil))
+	compressed := slices.Clone(req)
+	compressed[0] = 1
+	tests := []struct {
+		name, method string
+		body         []byte
+		status       string
+	}{
+		{"método desconhecido", "Solve", req, "12"},
+		{"comprimida", "Eval", compressed, "12"},
+		{"sem pedido", "Eval", nil, "3"},
+		{"frame cortado", "Eval", req[:len(req)-1], "3"},
+		{"protobuf inválido", "Eval", grpcFrame([]byte{0x0a, 0x05, 'a'}), "3"},
+		{"demasiado grande", "Eval", binary.BigEndian.AppendUint32([]byte{0}, maxServeBody+1), "8"},
+	}
+	for _, tc := range tests {
+		resp, msgs := callGRPC(t, client, srv.URL, tc.method, tc.body)
+		if got := resp.Trailer.Get("Grpc-Status"); got != tc.status || len(msgs) != 0 {
+			t.Errorf("%s: grpc-status %q e %d respostas, want %s e nenhuma", tc.name, got, len(msgs), tc.status)
+		}
+		if resp.Trailer.Get("Grpc-Message") == "" {
+			t.Errorf("%s: falta grpc-message", tc.name)
+		}
+	}
+	r, err := client.Post(srv.URL+grpcService+"Eval", "application/json", bytes.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("content-type JSON: estado %d, want %d", r.StatusCode, http.StatusUnsupportedMediaType)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Base de dados da ajuda: funções, operadores, constantes e comandos numa só
+// lista, de onde saem :func, :const, :operators e a pesquisa de :help. As
+// descrições vêm dos catálogos de messages.go; os comandos, das linhas do
+// texto de ajuda que começam por ":".
+
+// Tipos de entrada da ajuda.
+const (
+	helpFunction = "function"
+	helpOperator = "operator"
+	helpConstant = "constant"
+	helpCommand  = "command"
+)
+
+// helpEntry é uma entrada da ajuda.
+type helpEntry struct {
+	kind string
+	name string
+	sig  string // o que se escreve: sin(x), a // b, pi, :precision N
+	desc string
+	tags []string // categorias para a pesquisa (trig, stats, ...)
+}
+
+// helpKinds são as palavras que, em :help, listam um tipo inteiro.
+var helpKinds = map[string]string{
+	"function": helpFunction, "functions": helpFunction, "função": helpFunction, "funções": helpFunction,
+	"operator": helpOperator, "operators": helpOperator, "operador": helpOperator, "operadores": helpOperator,
+	"constant": helpConstant, "constants": helpConstant, "constante": helpConstant, "constantes": helpConstant,
+	"command": helpCommand, "commands": helpCommand, "comando": helpCommand, "comandos": helpCommand,
+}
+
+// funcTags agrupa as funções por tema, para pesquisas como ":help trig".
+var funcTags = map[string][]string{
+	"trig": {"sin", "cos", "tan", "to_rad", "to_deg", "to_grad", "from_grad", "angle2", "phase", "polar", "rect",
+		"normalize_angle", "bearing_to_math", "math_to_bearing"},
+	"stats": {"normal_cdf", "normal_pdf", "qnorm", "probit", "chi2_ppf", "t_ppf", "binom_pmf", "binom_cdf",
+		"poisson_pmf", "ma", "ema", "cumsum", "cumprod"},
+	"number": {"factorial", "fib", "fib_pair", "lucas", "catalan", "bell", "mobius", "liouville", "omega", "bigomega",
+		"digitsum", "digitalroot", "numdigits", "digits", "primorial", "prime_pi", "isqrt", "divmod_q", "divmod_r", "to_words", "roman", "goldenratio_approx", "prime_factors", "prime_factors_list"},
+	"poly":     {"poly", "polyfit", "polyeval", "polyderiv", "polyeval_at_roots", "solve_quadratic", "chebt", "chebu", "legendre", "legendre_roots"},
+	"linalg":   {"dot2", "dot3", "cross2", "cross3", "det2", "det3", "trace2", "trace3"},
+	"special":  {"zeta", "lambertw", "poch", "fallfact", "chebt", "chebu", "legendre"},
+	"calculus": {"sigma", "pi_prod", "integral_approx", "gradient_descent", "fixed_point"},
+	"rounding": {"floor", "ceil", "round", "floorm", "ceilm", "fmod", "remainder", "copysign"},
+}
+
+// describe procura key no catálogo da língua atual, e em português se faltar.
+func describe(catalog map[string]map[string]string, key string) string {
+	if s, ok := catalog[lang][key]; ok {
+		return s
+	}
+	return catalog["pt"][key]
+}
+
+// operatorSig escreve o operador como se usa: a + b, a%, c ? a : b.
+func operatorSig(op string) string {
+	switch op {
+	case "?:":
+		return "c ? a : b"
+	case "±":
+		return "a ± b"
+	case "%":
+		return "a%"
+	}
+	return "a " + op + " b"
+}
+
+// helpCommandRe apanha o comando no início de uma linha do texto de ajuda.
+var helpCommandRe = regexp.MustCompile(`^(:[a-z_]+)`)
+
+// helpEntries monta a base de dados na língua atual, por tipo e por nome.
+func helpEntries() []helpEntry {
+	var db []helpEntry
+	for name, d := range functions {
+		e := helpEntry{kind: helpFunction, name: name, sig: d.sig, desc: d.description()}
+		for tag, names := range funcTags {
+			if slices.Contains(names, name) {
+				e.tags = append(e.tags, tag)
+			}
+		}
+		db = append(db, e)
+	}
+	for op := range opDescriptions["pt"] {
+		db = append(db, helpEntry{kind: helpOperator, name: op, sig: operatorSig(op), desc: describe(opDescriptions, op)})
+	}
+	for name, v := range constants {
+		db = append(db, helpEntry{kind: helpConstant, name: name, sig: fmt.Sprintf("%s = %.15g", name, v), desc: describe(constDescriptions, name)})
+	}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(msg("help"), "\n") {
+		line = strings.TrimSpace(line)
+		m := helpCommandRe.FindString(line)
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		db = append(db, helpEntry{kind: helpCommand, name: m, sig: m, desc: line})
+	}
+	kinds := []string{helpFunction, helpOperator, helpConstant, helpCommand}
+	slices.SortFunc(db, func(a, b helpEntry) int {
+		if k := slices.Index(kinds, a.kind) - slices.Index(kinds, b.kind); k != 0 {
+			return k
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return db
+}
+
+// helpOfKind devolve as entradas de um tipo.
+func helpOfKind(kind string) []helpEntry {
+	return slices.DeleteFunc(helpEntries(), func(e helpEntry) bool { return e.kind != kind })
+}
+
+// printHelpEntries mostra uma entrada por linha, com as assinaturas alinhadas.
+// Nos comandos a descrição já é a linha inteira da ajuda.
+func printHelpEntries(out io.Writer, entries []helpEntry) {
+	w := 0
+	for _, e := range entries {
+		if e.kind != helpCommand {
+			w = max(w, len([]rune(e.sig)))
+		}
+	}
+	for _, e := range entries {
+		if e.kind == helpCommand {
+			fmt.Fprintf(out, "  %s\n", e.desc)
+			continue
+		}
+		pad := strings.Repeat(" ", max(0, w-len([]rune(e.sig))))
+		fmt.Fprintf(out, "  %s%s  %s\n", e.sig, pad, e.desc)
+	}
+}
+
+// searchHelp procura keyword (sem distinguir maiúsculas) nos nomes e nas
+// categorias. Uma palavra de helpKinds lista um tipo inteiro.
+func searchHelp(keyword string) []helpEntry {
+	kw := strings.ToLower(keyword)
+	if kind, ok := helpKinds[kw]; ok {
+		return helpOfKind(kind)
+	}
+	var found []helpEntry
+	for _, e := range helpEntries() {
+		if strings.Contains(e.name, kw) || slices.Contains(e.tags, kw) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// help trata ":help" e ":help palavra". Se a palavra é o nome de uma função,
+// mostra-a como :func; senão lista o que encontrar.
+func (s *Session) help(arg string) error {
+	if arg == "" {
+		printHelp(s.out)
+		return nil
+	}
+	kw := strings.ToLower(arg)
+	if _, ok := functions[kw]; ok {
+		return s.printFuncs(kw)
+	}
+	found := searchHelp(kw)
+	if len(found) == 0 {
+		return fmt.Errorf(msg("help_none"), arg)
+	}
+	printHelpEntries(s.out, found)
+	return nil
+}
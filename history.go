@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Histórico entre sessões: no fim de uma sessão interativa, as expressões
+// avaliadas são acrescentadas ao ficheiro de histórico, uma por linha e sem
+// resultados; no arranque carregam-se as últimas, que :history mostra.
+
+const defaultHistorySize = 100
+
+// historyPath devolve o ficheiro de histórico: o da flag --history-file, senão
+// CALC_HISTORY_FILE, senão ~/.calc_history ("" se não houver diretoria pessoal).
+func historyPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("CALC_HISTORY_FILE"); env != "" {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".calc_history")
+}
+
+// loadHistory lê as últimas n linhas do ficheiro; um ficheiro que ainda não
+// existe é um histórico vazio.
+func loadHistory(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, sc.Err()
+}
+
+// addHistory regista uma expressão avaliada nesta sessão (os comandos não
+// entram no histórico).
+func (s *Session) addHistory(line string) {
+	if !strings.HasPrefix(line, ":") {
+		s.history = append(s.history, line)
+	}
+}
+
+// saveHistory acrescenta ao ficheiro as expressões desta sessão.
+func (s *Session) saveHistory() error {
+	added := s.history[s.historyLoaded:]
+	if s.historyFile == "" || len(added) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(s.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strings.Join(added, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// printHistory mostra o histórico, numerado.
+func (s *Session) printHistory() {
+	for i, line := range s.history {
+		fmt.Fprintf(s.out, "%4d  %s\n", i+1, line)
+	}
+}
+
+// Registo automático: com CALC_LOG definida, cada avaliação com sucesso é
+// acrescentada ao ficheiro como uma linha JSON:
+//
+//	{"ts":"2024-01-15T10:30:00Z","expr":"2+2","result":4}
+
+// logEntry é uma linha do registo; result é uma string ("+Inf", "NaN") quando
+// o valor não tem representação em JSON.
+type logEntry struct {
+	TS     string `json:"ts"`
+	Expr   string `json:"expr"`
+	Result any    `json:"result"`
+}
+
+// openLog abre o ficheiro de CALC_LOG para acrescentar; nil se não estiver
+// definida.
+func openLog() (*os.File, error) {
+	path := os.Getenv("CALC_LOG")
+	if path == "" {
+		return nil, nil
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+}
+
+// logResult acrescenta uma avaliação ao registo. Um erro de escrita não
+// interrompe a sessão: mostra-se um aviso e o registo é desligado.
+func (s *Session) logResult(expr string, v float64) {
+	if s.log == nil {
+		return
+	}
+	e := logEntry{TS: time.Now().UTC().Format(time.RFC3339), Expr: expr, Result: v}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		e.Result = fmt.Sprint(v)
+	}
+	line, _ := json.Marshal(e)
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(s.errOut, msg("log_error")+"\n", err)
+		s.log.Close()
+		s.log = nil
+	}
+}
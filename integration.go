@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// integral_approx(f, a, b, n, método) — integral de f (expressão em x, ou o
+// nome de uma função de um argumento, como sin) entre a e b, por uma regra
+// composta com n subintervalos. n e o método são opcionais: por omissão
+// n = 100 e simpson. O método é um nome (simpson, trapezoid, midpoint,
+// gauss5) e não uma expressão, por isso todos os argumentos ficam por avaliar
+// (FuncDef.lazy) e a própria função avalia a, b e n.
+
+const (
+	defaultIntegralN = 100
+	maxIntegralN     = 1000000
+)
+
+// quadrature é uma regra composta: integra f em [a, b] com n subintervalos.
+// order é a ordem do erro (h^order), usada na estimativa de Richardson; even
+// diz que n tem de ser par.
+type quadrature struct {
+	rule  func(f func(float64) (float64, error), a, b float64, n int) (float64, error)
+	order int
+	even  bool
+}
+
+var quadratures = map[string]quadrature{
+	"simpson":   {simpsonRule, 4, true},
+	"trapezoid": {trapezoidRule, 2, false},
+	"midpoint":  {midpointRule, 2, false},
+	"gauss5":    {gauss5Rule, 10, false},
+}
+
+// sumAt soma w(i)·f(x(i)) para i de 0 a n-1.
+func sumAt(f func(float64) (float64, error), n int, x func(i int) float64, w func(i int) float64) (float64, error) {
+	var s float64
+	for i := range n {
+		y, err := f(x(i))
+		if err != nil {
+			return 0, err
+		}
+		s += w(i) * y
+	}
+	return s, nil
+}
+
+func one(int) float64 { return 1 }
+
+// trapezoidRule: h·(f0/2 + f1 + ... + f(n-1) + fn/2).
+func trapezoidRule(f func(float64) (float64, error), a, b float64, n int) (float64, error) {
+	h := (b - a) / float64(n)
+	s, err := sumAt(f, n+1, func(i int) float64 { return a + float64(i)*h }, func(i int) float64 {
+		if i == 0 || i == n {
+			return 0.5
+		}
+		return 1
+	})
+	return h * s, err
+}
+
+// midpointRule avalia f no meio de cada subintervalo.
+func midpointRule(f func(float64) (float64, error), a, b float64, n int) (float64, error) {
+	h := (b - a) / float64(n)
+	s, err := sumAt(f, n, func(i int) float64 { return a + (float64(i)+0.5)*h }, one)
+	return h * s, err
+}
+
+// simpsonRule: h/3·(f0 + 4f1 + 2f2 + ... + 4f(n-1) + fn); n tem de ser par
+// (integralApprox arredonda-o para cima).
+func simpsonRule(f func(float64) (float64, error), a, b float64, n int) (float64, error) {
+	h := (b - a) / float64(n)
+	s, err := sumAt(f, n+1, func(i int) float64 { return a + float64(i)*h }, func(i int) float64 {
+		switch {
+		case i == 0 || i == n:
+			return 1
+		case i%2 == 1:
+			return 4
+		}
+		return 2
+	})
+	return h / 3 * s, err
+}
+
+// gauss5Rule aplica Gauss-Legendre com 5 pontos em cada subintervalo: exata
+// para polinómios até grau 9.
+func gauss5Rule(f func(float64) (float64, error), a, b float64, n int) (float64, error) {
+	xs, ws := gaussLegendre(5)
+	h := (b - a) / float64(n)
+	s, err := sumAt(f, 5*n, func(i int) float64 {
+		c := a + (float64(i/5)+0.5)*h
+		return c + h/2*xs[i%5]
+	}, func(i int) float64 { return ws[i%5] })
+	return h / 2 * s, err
+}
+
+// integrand devolve f como função de x. Um nome de função sozinho (sin)
+// chega do shunting-yard como uma chamada sem argumentos e aplica-se a x.
+func integrand(t token, ctx *EvalContext) (func(float64) (float64, error), func(), error) {
+	body := t.lazy[0]
+	if len(body) == 1 && body[0].typ == tFunc && body[0].argc == 0 && body[0].lazy == nil {
+		if functions[body[0].val].arity != 1 {
+			return nil, nil, errAt(body[0].offset, fmt.Errorf(msg(functions[t.val].usage), t.val))
+		}
+		call := body[0]
+		call.argc = 1
+		body = []token{{typ: tIdent, val: "x", offset: call.offset}, call}
+	}
+	lazy := t
+	lazy.lazy = [][]token{body}
+	return solverArgs(lazy, ctx)
+}
+
+// integralApprox calcula a integral e, ao lado, uma estimativa do erro pela
+// extrapolação de Richardson: com erro ~ h^p, I(n) - I(2n) ≈ (1 - 2^-p)·erro.
+func integralApprox(t token, args []float64, ctx *EvalContext) (float64, error) {
+	if len(t.lazy)+len(args) > 5 {
+		return 0, errAt(t.offset, fmt.Errorf(msg("usage_integral"), t.val))
+	}
+	bounds := make([]float64, 0, 3)
+	for _, rpn := range t.lazy[1:min(len(t.lazy), 4)] {
+		v, err := evalRPN(rpn, ctx)
+		if err != nil {
+			return 0, err
+		}
+		bounds = append(bounds, v)
+	}
+	a, b, n := bounds[0], bounds[1], int64(defaultIntegralN)
+	if len(bounds) == 3 {
+		var err error
+		if n, err = asInt(t.val, bounds[2]); err != nil {
+			return 0, err
+		}
+		if n < 1 || n > maxIntegralN {
+			return 0, fmt.Errorf(msg("integral_n"), t.val, maxIntegralN)
+		}
+	}
+	method := "simpson"
+	if len(t.lazy) == 5 {
+		m := t.lazy[4]
+		if len(m) != 1 || m[0].typ != tIdent {
+			return 0, errAt(t.offset, fmt.Errorf(msg("usage_integral"), t.val))
+		}
+		method = m[0].val
+	}
+	q, ok := quadratures[method]
+	if !ok {
+		return 0, errAt(t.lazy[4][0].offset, fmt.Errorf(msg("integral_method"), method))
+	}
+	if q.even {
+		// antes das duas estimativas: com n = 1 as duas seriam com n = 2
+		n += n % 2
+	}
+	f, restore, err := integrand(t, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	coarse, err := q.rule(f, a, b, int(n))
+	if err != nil {
+		return 0, err
+	}
+	fine, err := q.rule(f, a, b, 2*int(n))
+	if err != nil {
+		return 0, err
+	}
+	estimate := math.Abs(fine-coarse) / (1 - math.Pow(2, -float64(q.order)))
+	ctx.note(msg("integral_estimate"), method, n, estimate)
+	return coarse, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Modo :interval — cada valor é um intervalo [lo, hi] que contém o valor
+// verdadeiro. Os literais e as variáveis são intervalos pontuais [x, x]; as
+// operações alargam o resultado de um ULP para cada lado, para que o
+// arredondamento do float64 fique dentro dos limites. interval(a, b) escreve
+// um intervalo, ex.: interval(2.999, 3.001)^2.
+
+// Interval é um intervalo fechado [lo, hi].
+type Interval struct {
+	lo, hi float64
+}
+
+func point(x float64) Interval { return Interval{x, x} }
+
+// outward alarga o intervalo de um ULP para cada lado. Os zeros ficam: um
+// resultado nulo de +, -, * ou sin(0) é exato.
+func (a Interval) outward() Interval {
+	if a.lo != 0 {
+		a.lo = math.Nextafter(a.lo, math.Inf(-1))
+	}
+	if a.hi != 0 {
+		a.hi = math.Nextafter(a.hi, math.Inf(1))
+	}
+	return a
+}
+
+func (a Interval) isPoint() bool { return a.lo == a.hi }
+
+func (a Interval) contains(x float64) bool { return a.lo <= x && x <= a.hi }
+
+// mid é o ponto médio, usado como valor de ans fora do modo :interval.
+func (a Interval) mid() float64 {
+	if a.isPoint() {
+		return a.lo
+	}
+	return a.lo + (a.hi-a.lo)/2
+}
+
+// hull é o menor intervalo que contém todos os valores.
+func hull(vs ...float64) Interval {
+	r := point(vs[0])
+	for _, v := range vs[1:] {
+		r.lo, r.hi = min(r.lo, v), max(r.hi, v)
+	}
+	return r
+}
+
+// intervalConstant alarga as constantes irracionais (pi, e, ...), que em
+// float64 já são só aproximações.
+func intervalConstant(c float64) Interval {
+	if c == math.Trunc(c) {
+		return point(c)
+	}
+	return point(c).outward()
+}
+
+// evalInterval avalia a forma pós-fixa com aritmética de intervalos.
+func evalInterval(rpn []token, ctx *EvalContext) (Interval, error) {
+	var st []Interval
+	pop := func(n int) []Interval {
+		args := append([]Interval(nil), st[len(st)-n:]...)
+		st = st[:len(st)-n]
+		return args
+	}
+	for _, t := range rpn {
+		switch t.typ {
+		case tNumber:
+			v, err := strconv.ParseFloat(t.val, 64)
+			if err != nil {
+				return Interval{}, err
+			}
+			st = append(st, point(v))
+		case tIdent:
+			switch v, ok := ctx.vars[t.val]; {
+			case t.val == "ans" && ctx.intervalAns != nil:
+				st = append(st, *ctx.intervalAns)
+			case t.val == "ans":
+				st = append(st, point(ctx.lastAns))
+			case ctx.varResults[t.val].interval != nil:
+				st = append(st, *ctx.varResults[t.val].interval)
+			case ok:
+				st = append(st, point(v))
+			default:
+				c, ok := constants[t.val]
+				if !ok {
+					return Interval{}, errAt(t.offset, fmt.Errorf(msg("unknown_ident"), t.val))
+				}
+				st = append(st, intervalConstant(c))
+			}
+		case tOp:
+			if ops[t.val].unary {
+				if len(st) < 1 {
+					return Interval{}, errAt(t.offset, errors.New(msg("unary_no_operand")))
+				}
+				switch a := st[len(st)-1]; t.val {
+				case "u-":
+					st[len(st)-1] = Interval{-a.hi, -a.lo}
+				case "%":
+					st[len(st)-1] = Interval{a.lo / 100, a.hi / 100}.outward()
+				}
+				continue
+			}
+			if len(st) < 2 {
+				return Interval{}, errAt(t.offset, errors.New(msg("binary_few_operands")))
+			}
+			ab := pop(2)
+			res, err := intervalBinary(t, ab[0], ab[1])
+			if err != nil {
+				return Interval{}, err
+			}
+			st = append(st, res)
+		case tFunc:
+			if t.lazy != nil {
+				return Interval{}, errAt(t.offset, fmt.Errorf(msg("interval_unsupported"), t.val))
+			}
+			nargs, err := argCount(t)
+			if err != nil {
+				return Interval{}, err
+			}
+			if len(st) < nargs {
+				return Interval{}, errAt(t.offset, fmt.Errorf(msg("func_few_args"), t.val))
+			}
+			res, err := intervalFunc(t, pop(nargs), ctx)
+			if err != nil {
+				return Interval{}, errAt(t.offset, err)
+			}
+			st = append(st, res)
+		}
+	}
+	if len(st) != 1 {
+		return Interval{}, errors.New(msg("invalid_expr"))
+	}
+	return st[0], nil
+}
+
+func intervalBinary(t token, a, b Interval) (Interval, error) {
+	switch t.val {
+	case "+":
+		return Interval{a.lo + b.lo, a.hi + b.hi}.outward(), nil
+	case "-":
+		return Interval{a.lo - b.hi, a.hi - b.lo}.outward(), nil
+	case "*", "of":
+		return hull(a.lo*b.lo, a.lo*b.hi, a.hi*b.lo, a.hi*b.hi).outward(), nil
+	case "/", "//":
+		if b.contains(0) {
+			return Interval{}, errAt(t.offset, errors.New(msg("interval_div_zero")))
+		}
+		q := hull(a.lo/b.lo, a.lo/b.hi, a.hi/b.lo, a.hi/b.hi).outward()
+		if t.val == "//" {
+			q = Interval{math.Floor(q.lo), math.Floor(q.hi)}
+		}
+		return q, nil
+	case "^":
+		return intervalPow(t, a, b)
+	}
+	return Interval{}, errAt(t.offset, fmt.Errorf(msg("interval_unsupported"), t.val))
+}
+
+// intervalPow trata à parte os expoentes inteiros, que admitem bases
+// negativas; com expoente real a base tem de ser positiva.
+func intervalPow(t token, a, b Interval) (Interval, error) {
+	if b.isPoint() && b.lo == math.Trunc(b.lo) {
+		n := b.lo
+		if n < 0 {
+			if a.contains(0) {
+				return Interval{}, errAt(t.offset, errors.New(msg("interval_div_zero")))
+			}
+			p, err := intervalPow(t, a, point(-n))
+			if err != nil {
+				return Interval{}, err
+			}
+			return hull(1/p.lo, 1/p.hi).outward(), nil
+		}
+		lo, hi := math.Pow(a.lo, n), math.Pow(a.hi, n)
+		if math.Mod(n, 2) == 0 && a.contains(0) {
+			return Interval{0, max(lo, hi)}.outward(), nil
+		}
+		return hull(lo, hi).outward(), nil
+	}
+	if a.lo < 0 {
+		return Interval{}, errAt(t.offset, errors.New(msg("interval_pow_negative")))
+	}
+	// com base positiva x^y é monótona em cada argumento: os extremos estão
+	// nos cantos
+	return hull(math.Pow(a.lo, b.lo), math.Pow(a.lo, b.hi), math.Pow(a.hi, b.lo), math.Pow(a.hi, b.hi)).outward(), nil
+}
+
+// monotoneFuncs são as funções de um argumento crescentes no domínio, em que
+// basta avaliar os extremos.
+var monotoneFuncs = map[string]bool{
+	"sqrt": true, "log": true, "ln": true, "cbrt": true,
+	"floor": true, "ceil": true, "round": true,
+	"to_rad": true, "to_deg": true, "to_grad": true, "from_grad": true,
+}
+
+func intervalFunc(t token, a []Interval, ctx *EvalContext) (Interval, error) {
+	def := functions[t.val]
+	endpoint := func(x float64) (float64, error) { return def.fn(ctx, x) }
+	switch {
+	case t.val == "interval":
+		return hull(a[0].lo, a[0].hi, a[1].lo, a[1].hi), nil
+	case monotoneFuncs[t.val]:
+		lo, err := endpoint(a[0].lo)
+		if err != nil {
+			return Interval{}, err
+		}
+		hi, err := endpoint(a[0].hi)
+		if err != nil {
+			return Interval{}, err
+		}
+		if math.IsNaN(lo) || math.IsNaN(hi) {
+			return Interval{}, fmt.Errorf(msg("interval_domain"), t.val)
+		}
+		r := Interval{lo, hi}
+		if t.val == "floor" || t.val == "ceil" || t.val == "round" {
+			return r, nil
+		}
+		return r.outward(), nil
+	case t.val == "abs":
+		if a[0].contains(0) {
+			return Interval{0, max(-a[0].lo, a[0].hi)}, nil
+		}
+		return hull(math.Abs(a[0].lo), math.Abs(a[0].hi)), nil
+	case t.val == "max":
+		return Interval{max(a[0].lo, a[1].lo), max(a[0].hi, a[1].hi)}, nil
+	case t.val == "min":
+		return Interval{min(a[0].lo, a[1].lo), min(a[0].hi, a[1].hi)}, nil
+	case t.val == "sin":
+		return intervalSin(Interval{ctx.toRadians(a[0].lo), ctx.toRadians(a[0].hi)}), nil
+	case t.val == "cos":
+		return intervalSin(Interval{ctx.toRadians(a[0].lo) + math.Pi/2, ctx.toRadians(a[0].hi) + math.Pi/2}), nil
+	case t.val == "tan":
+		x := Interval{ctx.toRadians(a[0].lo), ctx.toRadians(a[0].hi)}
+		// tan é crescente entre assíntotas; não pode haver nenhuma no meio
+		if k := math.Ceil((x.lo - math.Pi/2) / math.Pi); math.Pi/2+k*math.Pi <= x.hi {
+			return Interval{}, fmt.Errorf(msg("interval_domain"), t.val)
+		}
+		return Interval{math.Tan(x.lo), math.Tan(x.hi)}.outward(), nil
+	}
+	// as restantes só com argumentos pontuais: não se sabe como variam
+	args := make([]float64, len(a))
+	for i, x := range a {
+		if !x.isPoint() {
+			return Interval{}, fmt.Errorf(msg("interval_unsupported"), t.val)
+		}
+		args[i] = x.lo
+	}
+	v, err := def.fn(ctx, args...)
+	if err != nil {
+		return Interval{}, err
+	}
+	return point(v), nil
+}
+
+// intervalSin procura os máximos (π/2 + 2kπ) e mínimos (-π/2 + 2kπ) dentro
+// do intervalo; fora deles sin é monótono e basta olhar para os extremos.
+func intervalSin(x Interval) Interval {
+	if x.hi-x.lo >= 2*math.Pi {
+		return Interval{-1, 1}
+	}
+	r := hull(math.Sin(x.lo), math.Sin(x.hi)).outward()
+	peak := func(c float64) bool {
+		k := math.Ceil((x.lo - c) / (2 * math.Pi))
+		return c+2*k*math.Pi <= x.hi
+	}
+	if peak(math.Pi / 2) {
+		r.hi = 1
+	}
+	if peak(-math.Pi / 2) {
+		r.lo = -1
+	}
+	return Interval{max(r.lo, -1), min(r.hi, 1)}
+}
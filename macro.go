@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Macros: ":macro area := :precision 4; pi * $1^2" define o comando :area, e
+// ":area 5" corre ":precision 4" e depois "pi * 5^2". Os passos separam-se
+// por ;, e $1, $2, ... são os argumentos da chamada; se o corpo não usar
+// nenhum, os argumentos juntam-se ao fim do último passo. Os comandos da
+// calculadora têm precedência sobre as macros com o mesmo nome.
+
+// maxMacroDepth limita as macros que chamam macros (e as que se chamam a si
+// próprias).
+const maxMacroDepth = 16
+
+var (
+	macroRe      = regexp.MustCompile(`^:?([\pL_][\pL\pN_]*)\s*:=\s*(.+)$`)
+	macroParamRe = regexp.MustCompile(`\$(\d+)`)
+)
+
+// macro trata ":macro nome := passos", ":macro list" e ":macro delete nome".
+func (s *Session) macro(arg string) error {
+	sub, rest, _ := strings.Cut(arg, " ")
+	switch strings.ToLower(sub) {
+	case "", "list":
+		names := make([]string, 0, len(s.macros))
+		for n := range s.macros {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(s.out, "  :%s := %s\n", n, s.macros[n])
+		}
+		return nil
+	case "delete":
+		name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(rest), ":"))
+		if _, ok := s.macros[name]; !ok {
+			return fmt.Errorf(msg("macro_unknown"), name)
+		}
+		delete(s.macros, name)
+		return nil
+	}
+	m := macroRe.FindStringSubmatch(arg)
+	if m == nil {
+		return errors.New(msg("usage_macro"))
+	}
+	name, body := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+	if name == "list" || name == "delete" {
+		return errors.New(msg("usage_macro"))
+	}
+	s.macros[name] = body
+	fmt.Fprintf(s.out, ":%s := %s\n", name, body)
+	return nil
+}
+
+// expandMacro divide o corpo nos passos, já com os argumentos substituídos.
+// Um $n sem argumento correspondente é um erro.
+func expandMacro(name, body string, args []string) ([]string, error) {
+	var missing error
+	used := false
+	body = macroParamRe.ReplaceAllStringFunc(body, func(p string) string {
+		used = true
+		i, _ := strconv.Atoi(p[1:])
+		if i < 1 || i > len(args) {
+			missing = fmt.Errorf(msg("macro_args"), name, p)
+			return p
+		}
+		return args[i-1]
+	})
+	if missing != nil {
+		return nil, missing
+	}
+	var steps []string
+	for _, step := range splitTopLevel(body, ';') {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	if !used && len(args) > 0 && len(steps) > 0 {
+		steps[len(steps)-1] += " " + strings.Join(args, " ")
+	}
+	return steps, nil
+}
+
+// runMacro executa ":nome args"; ok=false se não há macro com esse nome.
+// O primeiro passo que falhar interrompe a macro.
+func (s *Session) runMacro(name, arg string) (quit, ok bool, err error) {
+	body, ok := s.macros[name]
+	if !ok {
+		return false, false, nil
+	}
+	if s.macroDepth >= maxMacroDepth {
+		return false, true, fmt.Errorf(msg("macro_depth"), name, maxMacroDepth)
+	}
+	steps, err := expandMacro(name, body, strings.Fields(arg))
+	if err != nil {
+		return false, true, err
+	}
+	s.macroDepth++
+	defer func() { s.macroDepth-- }()
+	for _, step := range steps {
+		quit, err := s.execLine(step)
+		if err != nil && !strings.HasPrefix(step, ":") {
+			// nos comandos a mensagem já diz de onde vem
+			err = fmt.Errorf("%s: %w", step, err)
+		}
+		if err != nil {
+			return false, true, err
+		}
+		if quit {
+			return true, true, nil
+		}
+	}
+	return false, true, nil
+}
@@ -0,0 +1,743 @@
+package main
+
+// Catálogo de mensagens do REPL, por língua. O português é a língua por
+// omissão e serve de recurso quando falta uma tradução.
+
+// lang é a língua atual das mensagens, alterada com :lang.
+var lang = "pt"
+
+var messages = map[string]map[string]string{
+	"pt": {
+		"banner":                   "Calculadora em Go — REPL (:help para ajuda)",
+		"error":                    "Erro:",
+		"sqrt_negative":            "sqrt de número negativo",
+		"needs_2_args":             "%s precisa de 2 argumentos",
+		"superscript_base":         "expoente sem base: %q",
+		"comma_separator":          "com vírgula decimal os argumentos separam-se com ;",
+		"invalid_char":             "caractere inválido: %q",
+		"comma_outside_func":       "vírgula fora de função",
+		"unbalanced_parens":        "parênteses desbalanceados",
+		"unsupported_func":         "função não suportada: %s",
+		"unknown_ident":            "identificador desconhecido: %s",
+		"unary_no_operand":         "operador unário sem operando",
+		"binary_few_operands":      "operador binário com poucos operandos",
+		"division_by_zero":         "divisão por zero",
+		"int_truncated":            "Nota: %.15g/%.15g truncado para %.15g (use // para divisão inteira explícita)",
+		"func_few_args":            "função %s com poucos argumentos",
+		"invalid_expr":             "expressão inválida",
+		"plusminus_multi":          "± dá mais do que um resultado",
+		"invalid_token":            "token inválido: %s",
+		"debug_infix":              "  infixa:",
+		"slow_confirm":             "Esta expressão pode ser lenta; continuar? [s/N] ",
+		"eval_cancelled":           "avaliação cancelada",
+		"constants":                "Constantes:",
+		"usage_maxcost":            "uso :maxcost N (inteiro não negativo)",
+		"usage_script":             "uso :script ficheiro.calc",
+		"usage_lang":               "uso :lang pt|en",
+		"unknown_command":          "comando desconhecido, use :help",
+		"non_finite":               "resultado não finito",
+		"eval_budget":              "avaliação interrompida: mais de %d passos",
+		"too_costly":               "expressão demasiado cara (custo %d, máximo %d)",
+		"serving":                  "API HTTP em POST /eval no endereço",
+		"grpc_serving":             "serviço gRPC calculator.Calculator (HTTP/2 sem TLS) no endereço",
+		"grpc_bad_message":         "mensagem gRPC mal formada",
+		"grpc_compressed":          "mensagens gRPC comprimidas não são suportadas",
+		"grpc_too_large":           "mensagem gRPC com %d bytes (máximo %d)",
+		"grpc_unknown_method":      "método gRPC desconhecido: %s",
+		"grpc_no_request":          "Eval precisa de uma mensagem EvalRequest",
+		"usage_cache":              "uso :cache on|off",
+		"needs_int":                "%s precisa de um argumento inteiro",
+		"needs_nonneg_int":         "%s precisa de um inteiro não negativo",
+		"int_range":                "%s: argumento fora do intervalo dos inteiros de 64 bits",
+		"exact_too_big":            "resultado exato demasiado grande",
+		"precision_loss":           "Perda de precisão: o resultado pode não ser exato (±%g). Considere o modo :exact.",
+		"usage_nowarn":             "uso :nowarn precision (ou :warn precision para voltar a ligar)",
+		"usage_precision":          "uso :precision N (0 a 30) ou :precision auto",
+		"usage_format":             "uso :format default|sci|frac",
+		"usage_range":              "uso: expr for x from a to b [step s]",
+		"range_step":               "o passo tem de ser diferente de zero e ir de a para b",
+		"range_var":                "%s não pode ser usada como variável do intervalo",
+		"range_too_long":           "intervalo com %d pontos (máximo %d)",
+		"usage_numberline":         "uso :numberline lo hi p1,p2,... ou :numberline p1,p2,...",
+		"func_min_args":            "função %s precisa de pelo menos %d argumentos",
+		"func_arg_count":           "função %s: esperados %d argumentos, recebidos %d",
+		"cf_terms":                 "o número de termos tem de estar entre 1 e 100",
+		"cf_ends":                  "a fração contínua termina ao fim de %d termos: %s",
+		"multiple_positive":        "o múltiplo m tem de ser positivo",
+		"loop_usage":               "uso: %s(expr, x, a, b), com x o nome de uma variável",
+		"prob_range":               "%s: a probabilidade p tem de estar entre 0 e 1",
+		"needs_positive":           "%s: %s tem de ser positivo",
+		"qnorm_args":               "qnorm aceita 1 ou 3 argumentos (p, mu, sigma), recebidos %d",
+		"qnorm_p":                  "p tem de estar estritamente entre 0 e 1",
+		"ma_window":                "a janela tem de ser um inteiro entre 1 e %d",
+		"ema_alpha":                "alfa tem de estar em ]0, 1]",
+		"bits_range":               "%s: é preciso 0 <= lo <= hi <= 63",
+		"bits_value":               "o valor %d não cabe em %d bits",
+		"needs_pos_int":            "%s precisa de um inteiro positivo",
+		"base_range":               "a base tem de estar entre 2 e 36",
+		"fib_overflow":             "F(n) não cabe num float64 para n > %d; use :exact para o valor exato",
+		"usage_verify":             "uso: %s(f, g, n), com f e g expressões em x",
+		"verify_samples":           "o número de pontos tem de estar entre 1 e %d",
+		"test_expected":            "esperado %.15g",
+		"test_summary":             "%d/%d testes passaram, %d falharam",
+		"usage_pi":                 "uso :pi N (1 a %d casas decimais)",
+		"pi_progress":              "A calcular %d casas decimais de π...",
+		"pi_done":                  "(%.1f s)",
+		"usage_multibase":          "uso :multibase [dec hex bin oct] ou :multibase off",
+		"bad_underscore":           "_ só pode separar dois dígitos, ex.: 1_000",
+		"func_arity":               "argumentos: %d",
+		"func_min_arity":           "argumentos: %d ou mais",
+		"func_example":             "exemplo:",
+		"usage_example":            "uso :example %s",
+		"usage_graph":              "uso :graph expr from a to b (ex.: :graph sin(x) from -pi to pi)",
+		"usage_gd":                 "uso: %s(f, x0, lr, passos), com f uma expressão em x",
+		"usage_fixed":              "uso: %s(g, x0, tol), com g uma expressão em x",
+		"solver_diverged":          "%s diverge (iteração %d)",
+		"solver_no_convergence":    "%s não convergiu em %d iterações",
+		"solver_iters":             "Nota: convergiu em %d iterações",
+		"usage_profile":            "uso :profile on|off|clear, ou :profile para ver a tabela",
+		"profile_empty":            "sem chamadas registadas (:profile on para começar)",
+		"profile_name":             "nome",
+		"profile_count":            "chamadas",
+		"profile_time":             "tempo total",
+		"stack_depth":              "Profundidade máxima da pilha: %d",
+		"verbose_push":             "%s → empilha %s, pilha: %s",
+		"verbose_apply":            "%s → desempilha %s, empilha %s, pilha: %s",
+		"simplify_cost":            "complexidade: %d → %d",
+		"usage_complexity":         "uso: %s(expr)",
+		"evaluated_in":             "(avaliado em %v)",
+		"sizeof_bytes":             "tamanho: %d bytes (float64, IEEE 754 de precisão dupla)",
+		"sizeof_bits":              "bits: %s %s %s (sinal, expoente, mantissa)",
+		"sizeof_class":             "classe: %s",
+		"sizeof_digits":            "dígitos significativos na forma mais curta: %d (um float64 distingue 15 a 17)",
+		"class_normal":             "normal",
+		"class_subnormal":          "subnormal",
+		"class_zero":               "zero",
+		"class_inf":                "infinito",
+		"class_nan":                "NaN (não é um número)",
+		"log_error":                "Aviso: registo de CALC_LOG desligado: %v",
+		"game_start":               "Pensei num número entre 1 e %d. Adivinha! (:quit para desistir)",
+		"game_higher":              "Mais alto.",
+		"game_lower":               "Mais baixo.",
+		"game_correct":             "Certo! Acertaste em %d tentativas.",
+		"game_quit":                "Era %d.",
+		"no_poly":                  "nenhum polinómio definido; use :poly a0 a1 ... ou polyfit",
+		"polyfit_args":             "uso: polyfit(x1, y1, ..., xn, yn, grau)",
+		"polyfit_degree":           "o grau tem de estar entre 0 e %d",
+		"polyfit_points":           "são precisos pelo menos %d pontos",
+		"polyfit_singular":         "polyfit: os pontos não determinam o polinómio (x repetidos?)",
+		"test_expected_exact":      "esperado %s",
+		"usage_def":                "uso :def nome := expr, e depois nome where p1=v1, p2=v2",
+		"def_reserved":             "%s é uma função ou constante",
+		"def_param":                "%q não é um parâmetro da fórmula (parâmetros: %s)",
+		"usage_whatif":             "uso: !x=5 volta a avaliar a última expressão com x = 5",
+		"whatif_none":              "ainda não há expressão para repetir",
+		"whatif_absent":            "%s não aparece em %s",
+		"interval_unsupported":     "%s não aceita intervalos (modo :interval)",
+		"interval_div_zero":        "divisão por um intervalo que contém zero",
+		"interval_pow_negative":    "potência de expoente não inteiro com base que pode ser negativa",
+		"interval_domain":          "%s: o intervalo sai do domínio da função",
+		"unit_unknown":             "unidade desconhecida: %s",
+		"unit_unclosed":            "falta o ] da unidade",
+		"unit_after_number":        "a unidade [..] tem de vir logo a seguir a um número, ex.: 5 [m]",
+		"unit_mismatch":            "unidades incompatíveis: %s %s %s",
+		"unit_dimensionless":       "%s só aceita valores sem unidade",
+		"unit_exponent":            "expoente %g não dá uma unidade racional",
+		"cheb_domain":              "%s: com n não inteiro, x tem de estar em [-1, 1]",
+		"legendre_points":          "legendre_roots: n tem de estar entre 1 e %d",
+		"lambertw_domain":          "lambertW só é real para x >= -1/e (%.6g)",
+		"zeta_pole":                "zeta tem um polo em s = 1",
+		"ternary_no_colon":         "falta o : do condicional c ? a : b",
+		"ternary_no_question":      ": sem o ? do condicional c ? a : b",
+		"usage_repeat":             "uso: :repeat N, com N entre 1 e %d",
+		"undo_none":                "não há nada para desfazer",
+		"undone":                   "Desfeito: %s",
+		"char_unclosed":            "falta fechar as aspas",
+		"string_position":          "texto entre aspas só é aceite nos separadores de number_format",
+		"usage_number_format":      "uso: %s(x, casas, sep_decimal, sep_milhares), com os separadores entre aspas, ex.: \",\" e \".\"",
+		"number_format_separators": "number_format: o separador decimal não pode ser vazio nem igual ao de milhares",
+		"number_format_decimals":   "number_format: as casas decimais têm de estar entre 0 e 20",
+		"quadratic_degenerate":     "solve_quadratic: com a = b = 0 não há equação para resolver",
+		"quadratic_complex":        "aviso: raízes complexas %.12g ± %.12gi",
+		"sieve_too_big":            "%s: n tem de ser no máximo %d",
+		"sieve_slow":               "aviso: crivo até %d, pode demorar",
+		"usage_integral":           "uso: %s(f, a, b, n, método), com f uma expressão em x; n e o método são opcionais",
+		"integral_n":               "%s: n tem de estar entre 1 e %d",
+		"integral_method":          "método de integração desconhecido: %s (simpson, trapezoid, midpoint, gauss5)",
+		"integral_estimate":        "%s com n = %d: erro estimado ≈ %.3g",
+		"usage_macro":              "uso: :macro nome := passo1; passo2 ($1, $2, ... são os argumentos), :macro list, :macro delete nome",
+		"macro_unknown":            "não há nenhuma macro %s",
+		"macro_args":               ":%s: falta o argumento %s",
+		"macro_depth":              ":%s: macros encaixadas a mais (máximo %d)",
+		"help_none":                "nada na ajuda sobre %q",
+		"usage_tabulate":           "uso: %s(f, a, b, passo), com f uma expressão em x ou o nome de uma função",
+		"to_words_range":           "to_words: n tem de estar entre 0 e %d",
+		"roman_range":              "roman: n tem de estar entre 1 e 3999",
+		"roman_invalid":            "numeral romano inválido: %s (de I a MMMCMXCIX)",
+		"usage_roman":              "uso: :roman XIV",
+		"goldenratio_range":        "goldenratio_approx: n tem de estar entre 1 e %d",
+		"goldenratio_step":         "  fib(%d)/fib(%d) = %.15g (%+.3g de φ)",
+		"percent_position":         "% só pode vir depois de um número ou de um parêntese: 15%, (a+b)%",
+		"help": `Calculadora Go — exemplos:
+  2+2*3
+  (1+2)^3/9
+  sqrt(2), log(100), ln(e), abs(-3.5), |-3.5|
+  cbrt(-8) = -2 (ao contrário de (-8)^(1/3), que dá NaN)
+  sin(pi/2), cos(0), tan(pi/4)
+  max(3, 9), min(4, -2)
+  poly(3, 1, 2, 1) = 1 + 2·3 + 3² (coeficientes por grau crescente)
+  Use ans para o último resultado, ex.: 1+ans
+  Variáveis: x = 2*pi, depois sin(x); :vars lista as variáveis
+  Tabelas: eval x^2 for x from 1 to 5 [step 0.5]
+  Comandos: :quit para sair, :help para ajuda, :const para listar constantes, :func para listar funções
+            :func <nome> mostra a ajuda de uma função, com um exemplo
+            :help palavra procura funções, operadores, constantes e comandos (:help trig, :help operator, :help prec)
+            :operators lista os operadores
+            :pretty <expr> mostra a expressão na forma canónica
+            :rpn "2 3 + 4 *" avalia uma expressão pós-fixa, :debug on|off mostra a RPN
+            :maxcost N define o custo a partir do qual é pedida confirmação
+            :intmode on|off faz de / uma divisão inteira (// é sempre divisão inteira)
+            :script ficheiro.calc executa um ficheiro na sessão atual
+            :lang pt|en muda a língua das mensagens
+            :cache on|off guarda resultados de expressões repetidas
+            :exact on|off calcula inteiros e frações sem perda de precisão, ex.: factorial(100), 1/3 + 1/6 (:approx desliga)
+            :nowarn precision desliga o aviso de perda de precisão acima de 2^53
+            :precision N|auto fixa as casas decimais, :format default|sci|frac escolhe a notação (frac: 3/4)
+            :chart x^2 for x from 1 to 10 desenha um gráfico de barras
+            :numberline 0 10 2,5.5,8 mostra pontos numa reta numérica
+            :sum x^2 for x from 1 to 100 soma os valores; sigma(x^2, x, 1, 100) faz o mesmo numa expressão
+            :test corre as expressões de verificação embutidas (PASS/FAIL)
+            :history lista as expressões anteriores (guardadas em ~/.calc_history ou --history-file)
+            :pi 100 mostra π com 100 casas decimais
+            :multibase mostra os inteiros também em hexadecimal, binário e octal (:multibase hex, :multibase off)
+            :example trig mostra exemplos resolvidos (temas: algebra, finance, numbers, stats, trig)
+            :graph sin(x) from -pi to pi desenha o gráfico de uma expressão em x
+            :profile on conta as chamadas de funções e operadores; :profile mostra-as, :profile clear limpa
+            :stackdepth on|off mostra a profundidade máxima da pilha em cada avaliação
+            :deg, :grad e :rad mudam a unidade dos ângulos de sin, cos e tan
+            :verbose on|off mostra cada passo da avaliação (pilha da RPN)
+            :simplify <expr> calcula as partes constantes, ex.: x*(3+4) → x * 7
+            :time on|off mostra quanto demorou cada avaliação
+            :sizeof mostra como o último resultado é guardado (bits do float64, classe, ULP)
+            :percent on|off mostra os resultados em percentagem (0.5 → 50.0%)
+            :poly 1 2 1 define p(x) = 1 + 2x + x²: cada x = ... mostra p(x), polyeval(x) avalia-o; :poly off
+            :def area := pi * r^2 guarda uma fórmula; area where r=5 avalia-a (:def lista-as)
+            !x=5 repete a última expressão com x = 5 (também !pi=3), sem mudar as variáveis
+            :interval on|off  aritmética de intervalos: resultados [lo, hi] que contêm o valor exato
+            :units on|off  análise dimensional: 5 [m] * 3 [s] = 15 [m·s] (unidades SI: kg m s A K mol cd N J W Pa Hz C V Ohm)
+            :repeat N  volta a executar N vezes a última linha (expressão ou atribuição)
+            :undo  desfaz a última linha (ans e variáveis); outro :undo refá-la
+            :expand (a+b)^n  binómio de Newton: a^3 + 3a^2·b + 3a·b^2 + b^3
+            :csv_mode on|off  vírgula decimal e ; entre argumentos: max(1,5; 2) (como CALC_LOCALE=pt)
+            :watch expr|off  mostra expr a cada passo de sigma, pi_prod e dos ciclos for x from
+            :macro area := :precision 4; pi * $1^2  define o comando :area 5 (:macro list, :macro delete area)
+            :roman XIV  lê um numeral romano (I a MMMCMXCIX) e guarda o valor em ans; roman(14) faz o inverso`,
+	},
+	"en": {
+		"banner":                   "Go Calculator — REPL (:help for help)",
+		"error":                    "Error:",
+		"sqrt_negative":            "sqrt of a negative number",
+		"needs_2_args":             "%s needs 2 arguments",
+		"superscript_base":         "exponent without a base: %q",
+		"comma_separator":          "with a decimal comma, separate arguments with ;",
+		"invalid_char":             "invalid character: %q",
+		"comma_outside_func":       "comma outside a function",
+		"unbalanced_parens":        "unbalanced parentheses",
+		"unsupported_func":         "unsupported function: %s",
+		"unknown_ident":            "unknown identifier: %s",
+		"unary_no_operand":         "unary operator without an operand",
+		"binary_few_operands":      "binary operator with too few operands",
+		"division_by_zero":         "division by zero",
+		"int_truncated":            "Note: %.15g/%.15g truncated to %.15g (use // for explicit integer division)",
+		"func_few_args":            "function %s with too few arguments",
+		"invalid_expr":             "invalid expression",
+		"plusminus_multi":          "± gives more than one result",
+		"invalid_token":            "invalid token: %s",
+		"debug_infix":              "  infix: ",
+		"slow_confirm":             "This expression may be slow; proceed? [y/N] ",
+		"eval_cancelled":           "evaluation cancelled",
+		"constants":                "Constants:",
+		"usage_maxcost":            "usage :maxcost N (non-negative integer)",
+		"usage_script":             "usage :script file.calc",
+		"usage_lang":               "usage :lang pt|en",
+		"unknown_command":          "unknown command, use :help",
+		"non_finite":               "non-finite result",
+		"eval_budget":              "evaluation stopped: more than %d steps",
+		"too_costly":               "expression too costly (cost %d, maximum %d)",
+		"serving":                  "HTTP API on POST /eval at",
+		"grpc_serving":             "gRPC service calculator.Calculator (HTTP/2 without TLS) at",
+		"grpc_bad_message":         "malformed gRPC message",
+		"grpc_compressed":          "compressed gRPC messages are not supported",
+		"grpc_too_large":           "gRPC message of %d bytes (maximum %d)",
+		"grpc_unknown_method":      "unknown gRPC method: %s",
+		"grpc_no_request":          "Eval needs one EvalRequest message",
+		"usage_cache":              "usage :cache on|off",
+		"needs_int":                "%s needs an integer argument",
+		"needs_nonneg_int":         "%s needs a non-negative integer",
+		"int_range":                "%s: argument outside the 64-bit integer range",
+		"exact_too_big":            "exact result too large",
+		"precision_loss":           "Precision loss: result may not be exact (±%g). Consider :exact mode.",
+		"usage_nowarn":             "usage :nowarn precision (or :warn precision to turn it back on)",
+		"usage_precision":          "usage :precision N (0 to 30) or :precision auto",
+		"usage_format":             "usage :format default|sci|frac",
+		"usage_range":              "usage: expr for x from a to b [step s]",
+		"range_step":               "the step must be non-zero and go from a to b",
+		"range_var":                "%s cannot be used as the range variable",
+		"range_too_long":           "range with %d points (maximum %d)",
+		"usage_numberline":         "usage :numberline lo hi p1,p2,... or :numberline p1,p2,...",
+		"func_min_args":            "function %s needs at least %d arguments",
+		"func_arg_count":           "function %s: expected %d arguments, got %d",
+		"cf_terms":                 "the number of terms must be between 1 and 100",
+		"cf_ends":                  "the continued fraction ends after %d terms: %s",
+		"multiple_positive":        "the multiple m must be positive",
+		"loop_usage":               "usage: %s(expr, x, a, b), where x is a variable name",
+		"prob_range":               "%s: the probability p must be between 0 and 1",
+		"needs_positive":           "%s: %s must be positive",
+		"qnorm_args":               "qnorm takes 1 or 3 arguments (p, mu, sigma), got %d",
+		"qnorm_p":                  "p must be strictly between 0 and 1",
+		"ma_window":                "the window must be an integer between 1 and %d",
+		"ema_alpha":                "alpha must be in (0, 1]",
+		"bits_range":               "%s: requires 0 <= lo <= hi <= 63",
+		"bits_value":               "the value %d does not fit in %d bits",
+		"needs_pos_int":            "%s needs a positive integer",
+		"base_range":               "the base must be between 2 and 36",
+		"fib_overflow":             "F(n) does not fit in a float64 for n > %d; use :exact for the exact value",
+		"usage_verify":             "usage: %s(f, g, n), where f and g are expressions in x",
+		"verify_samples":           "the number of points must be between 1 and %d",
+		"test_expected":            "expected %.15g",
+		"test_summary":             "%d/%d tests passed, %d failed",
+		"usage_pi":                 "usage :pi N (1 to %d decimal places)",
+		"pi_progress":              "Computing %d decimal places of π...",
+		"pi_done":                  "(%.1f s)",
+		"usage_multibase":          "usage :multibase [dec hex bin oct] or :multibase off",
+		"bad_underscore":           "_ can only separate two digits, e.g. 1_000",
+		"func_arity":               "arguments: %d",
+		"func_min_arity":           "arguments: %d or more",
+		"func_example":             "example:",
+		"usage_example":            "usage :example %s",
+		"usage_graph":              "usage :graph expr from a to b (e.g. :graph sin(x) from -pi to pi)",
+		"usage_gd":                 "usage: %s(f, x0, lr, steps), where f is an expression in x",
+		"usage_fixed":              "usage: %s(g, x0, tol), where g is an expression in x",
+		"solver_diverged":          "%s diverges (iteration %d)",
+		"solver_no_convergence":    "%s did not converge in %d iterations",
+		"solver_iters":             "Note: converged in %d iterations",
+		"usage_profile":            "usage :profile on|off|clear, or :profile to show the table",
+		"profile_empty":            "no calls recorded (:profile on to start)",
+		"profile_name":             "name",
+		"profile_count":            "calls",
+		"profile_time":             "total time",
+		"stack_depth":              "Maximum stack depth: %d",
+		"verbose_push":             "%s → push %s, stack: %s",
+		"verbose_apply":            "%s → pop %s, push %s, stack: %s",
+		"simplify_cost":            "complexity: %d → %d",
+		"usage_complexity":         "usage: %s(expr)",
+		"evaluated_in":             "(evaluated in %v)",
+		"sizeof_bytes":             "size: %d bytes (float64, IEEE 754 double precision)",
+		"sizeof_bits":              "bits: %s %s %s (sign, exponent, mantissa)",
+		"sizeof_class":             "class: %s",
+		"sizeof_digits":            "significant digits in the shortest form: %d (a float64 resolves 15 to 17)",
+		"class_normal":             "normal",
+		"class_subnormal":          "subnormal",
+		"class_zero":               "zero",
+		"class_inf":                "infinity",
+		"class_nan":                "NaN (not a number)",
+		"log_error":                "Warning: CALC_LOG logging disabled: %v",
+		"game_start":               "I'm thinking of a number between 1 and %d. Guess! (:quit to give up)",
+		"game_higher":              "Higher.",
+		"game_lower":               "Lower.",
+		"game_correct":             "Correct! You got it in %d tries.",
+		"game_quit":                "It was %d.",
+		"no_poly":                  "no polynomial defined; use :poly a0 a1 ... or polyfit",
+		"polyfit_args":             "usage: polyfit(x1, y1, ..., xn, yn, degree)",
+		"polyfit_degree":           "the degree must be between 0 and %d",
+		"polyfit_points":           "at least %d points are needed",
+		"polyfit_singular":         "polyfit: the points do not determine the polynomial (repeated x?)",
+		"test_expected_exact":      "expected %s",
+		"usage_def":                "usage :def name := expr, then name where p1=v1, p2=v2",
+		"def_reserved":             "%s is a function or constant",
+		"def_param":                "%q is not a parameter of the formula (parameters: %s)",
+		"usage_whatif":             "usage: !x=5 re-evaluates the last expression with x = 5",
+		"whatif_none":              "there is no expression to repeat yet",
+		"whatif_absent":            "%s does not appear in %s",
+		"interval_unsupported":     "%s does not accept intervals (:interval mode)",
+		"interval_div_zero":        "division by an interval containing zero",
+		"interval_pow_negative":    "non-integer power of a base that may be negative",
+		"interval_domain":          "%s: the interval leaves the function domain",
+		"unit_unknown":             "unknown unit: %s",
+		"unit_unclosed":            "missing ] after the unit",
+		"unit_after_number":        "a [..] unit must follow a number, e.g. 5 [m]",
+		"unit_mismatch":            "unit mismatch: %s %s %s",
+		"unit_dimensionless":       "%s only accepts dimensionless values",
+		"unit_exponent":            "exponent %g does not give a rational unit",
+		"cheb_domain":              "%s: with non-integer n, x must be in [-1, 1]",
+		"legendre_points":          "legendre_roots: n must be between 1 and %d",
+		"lambertw_domain":          "lambertW is only real for x >= -1/e (%.6g)",
+		"zeta_pole":                "zeta has a pole at s = 1",
+		"ternary_no_colon":         "missing : in the conditional c ? a : b",
+		"ternary_no_question":      ": without the ? of the conditional c ? a : b",
+		"usage_repeat":             "usage: :repeat N, with N between 1 and %d",
+		"undo_none":                "there is nothing to undo",
+		"undone":                   "Undone: %s",
+		"char_unclosed":            "missing closing quote",
+		"string_position":          "quoted text is only accepted as a number_format separator",
+		"usage_number_format":      "usage: %s(x, decimals, decimal_sep, thousands_sep), with quoted separators, e.g. \".\" and \",\"",
+		"number_format_separators": "number_format: the decimal separator must be non-empty and differ from the thousands separator",
+		"number_format_decimals":   "number_format: decimals must be between 0 and 20",
+		"quadratic_degenerate":     "solve_quadratic: with a = b = 0 there is no equation to solve",
+		"quadratic_complex":        "warning: complex roots %.12g ± %.12gi",
+		"sieve_too_big":            "%s: n must be at most %d",
+		"sieve_slow":               "warning: sieving up to %d, this may take a while",
+		"usage_integral":           "usage: %s(f, a, b, n, method), where f is an expression in x; n and the method are optional",
+		"integral_n":               "%s: n must be between 1 and %d",
+		"integral_method":          "unknown integration method: %s (simpson, trapezoid, midpoint, gauss5)",
+		"integral_estimate":        "%s with n = %d: estimated error ≈ %.3g",
+		"usage_macro":              "usage: :macro name := step1; step2 ($1, $2, ... are the arguments), :macro list, :macro delete name",
+		"macro_unknown":            "no macro named %s",
+		"macro_args":               ":%s: missing argument %s",
+		"macro_depth":              ":%s: macros nested too deeply (at most %d)",
+		"help_none":                "nothing in the help about %q",
+		"usage_tabulate":           "usage: %s(f, a, b, step), where f is an expression in x or a function name",
+		"to_words_range":           "to_words: n must be between 0 and %d",
+		"roman_range":              "roman: n must be between 1 and 3999",
+		"roman_invalid":            "invalid Roman numeral: %s (I to MMMCMXCIX)",
+		"usage_roman":              "usage: :roman XIV",
+		"goldenratio_range":        "goldenratio_approx: n must be between 1 and %d",
+		"goldenratio_step":         "  fib(%d)/fib(%d) = %.15g (%+.3g from φ)",
+		"percent_position":         "% must follow a number or a closing parenthesis: 15%, (a+b)%",
+		"help": `Go Calculator — examples:
+  2+2*3
+  (1+2)^3/9
+  sqrt(2), log(100), ln(e), abs(-3.5), |-3.5|
+  cbrt(-8) = -2 (unlike (-8)^(1/3), which gives NaN)
+  sin(pi/2), cos(0), tan(pi/4)
+  max(3, 9), min(4, -2)
+  poly(3, 1, 2, 1) = 1 + 2·3 + 3² (coefficients in increasing degree)
+  Use ans for the last result, e.g. 1+ans
+  Variables: x = 2*pi, then sin(x); :vars lists the variables
+  Tables: eval x^2 for x from 1 to 5 [step 0.5]
+  Commands: :quit to exit, :help for help, :const to list constants, :func to list functions
+            :func <name> shows help for one function, with an example
+            :help keyword searches functions, operators, constants and commands (:help trig, :help operator, :help prec)
+            :operators lists the operators
+            :pretty <expr> shows the expression in canonical form
+            :rpn "2 3 + 4 *" evaluates a postfix expression, :debug on|off shows the RPN
+            :maxcost N sets the cost above which confirmation is requested
+            :intmode on|off makes / an integer division (// is always integer division)
+            :script file.calc runs a file in the current session
+            :lang pt|en switches the message language
+            :cache on|off stores results of repeated expressions
+            :exact on|off computes integers and fractions without precision loss, e.g. factorial(100), 1/3 + 1/6 (:approx turns it off)
+            :nowarn precision turns off the precision loss warning above 2^53
+            :precision N|auto fixes the decimal places, :format default|sci|frac picks the notation (frac: 3/4)
+            :chart x^2 for x from 1 to 10 draws a bar chart
+            :numberline 0 10 2,5.5,8 shows points on a number line
+            :sum x^2 for x from 1 to 100 adds up the values; sigma(x^2, x, 1, 100) does the same inside an expression
+            :test runs the built-in self-test expressions (PASS/FAIL)
+            :history lists previous expressions (kept in ~/.calc_history or --history-file)
+            :pi 100 shows π to 100 decimal places
+            :multibase also shows integers in hex, binary and octal (:multibase hex, :multibase off)
+            :example trig shows worked examples (topics: algebra, finance, numbers, stats, trig)
+            :graph sin(x) from -pi to pi plots an expression in x
+            :profile on counts function and operator calls; :profile shows them, :profile clear resets
+            :stackdepth on|off shows the maximum stack depth of each evaluation
+            :deg, :grad and :rad change the angle unit of sin, cos and tan
+            :verbose on|off shows each evaluation step (the RPN stack)
+            :simplify <expr> folds the constant parts, e.g. x*(3+4) → x * 7
+            :time on|off shows how long each evaluation took
+            :sizeof shows how the last result is stored (float64 bits, class, ULP)
+            :percent on|off shows results as percentages (0.5 → 50.0%)
+            :poly 1 2 1 defines p(x) = 1 + 2x + x²: each x = ... shows p(x), polyeval(x) evaluates it; :poly off
+            :def area := pi * r^2 stores a formula; area where r=5 evaluates it (:def lists them)
+            !x=5 repeats the last expression with x = 5 (also !pi=3), without changing variables
+            :interval on|off  interval arithmetic: results [lo, hi] that contain the exact value
+            :units on|off  dimensional analysis: 5 [m] * 3 [s] = 15 [m·s] (SI units: kg m s A K mol cd N J W Pa Hz C V Ohm)
+            :repeat N  runs the last line again N times (expression or assignment)
+            :undo  undoes the last line (ans and variables); another :undo redoes it
+            :expand (a+b)^n  binomial expansion: a^3 + 3a^2·b + 3a·b^2 + b^3
+            :csv_mode on|off  decimal comma and ; between arguments: max(1,5; 2) (like CALC_LOCALE=pt)
+            :watch expr|off  shows expr at each step of sigma, pi_prod and for x from loops
+            :macro area := :precision 4; pi * $1^2  defines the command :area 5 (:macro list, :macro delete area)
+            :roman XIV  reads a Roman numeral (I to MMMCMXCIX) and stores its value in ans; roman(14) does the reverse`,
+	},
+}
+
+// msg devolve a mensagem com a chave dada na língua atual.
+func msg(key string) string {
+	if m, ok := messages[lang][key]; ok {
+		return m
+	}
+	if m, ok := messages["pt"][key]; ok {
+		return m
+	}
+	return key
+}
+
+// funcDescriptions descreve cada função de functions, para :func.
+var funcDescriptions = map[string]map[string]string{
+	"pt": {
+		"sin":                "seno de x (em radianos, ou graus/grados com :deg/:grad)",
+		"cos":                "cosseno de x (em radianos, ou graus/grados com :deg/:grad)",
+		"tan":                "tangente de x (em radianos, ou graus/grados com :deg/:grad); enorme, não infinita, perto de pi/2",
+		"sqrt":               "raiz quadrada; erro para x < 0",
+		"log":                "logaritmo de base 10; -Inf em 0, NaN para x < 0",
+		"ln":                 "logaritmo natural; -Inf em 0, NaN para x < 0",
+		"abs":                "valor absoluto, também |x|",
+		"floor":              "maior inteiro <= x",
+		"ceil":               "menor inteiro >= x",
+		"round":              "inteiro mais próximo; as metades afastam-se do zero",
+		"max":                "o maior dos dois valores",
+		"min":                "o menor dos dois valores",
+		"divmod_q":           "quociente da divisão inteira por defeito, floor(a/b)",
+		"divmod_r":           "resto da divisão inteira por defeito, com o sinal de b",
+		"copysign":           "|x| com o sinal de y",
+		"remainder":          "resto IEEE 754, em [-y/2, y/2]",
+		"dim":                "diferença positiva, max(x-y, 0)",
+		"cbrt":               "raiz cúbica real (ao contrário de x^(1/3) para x < 0)",
+		"isqrt":              "raiz quadrada inteira, floor(sqrt(n)), para n >= 0",
+		"factorial":          "n!, para inteiros n >= 0; +Inf acima de 170 (exato com :exact)",
+		"poly":               "polinómio a0 + a1·x + a2·x² + ... (Horner)",
+		"polyderiv":          "derivada do polinómio de poly, em x",
+		"polyeval_at_roots":  "(x-r1)(x-r2)..., o polinómio mónico com essas raízes",
+		"cf":                 "mostra os n primeiros termos da fração contínua de x e devolve x",
+		"cf_convergent":      "n-ésima convergente p/q da fração contínua de x (p/q com :debug on)",
+		"floorm":             "maior múltiplo de m <= x (m > 0)",
+		"ceilm":              "menor múltiplo de m >= x (m > 0)",
+		"det2":               "determinante de [[a,b],[c,d]]",
+		"det3":               "determinante da matriz 3×3 dada por linhas",
+		"trace2":             "traço de [[a,b],[c,d]], a+d",
+		"trace3":             "traço da matriz 3×3 dada por linhas",
+		"dot2":               "produto escalar de dois vetores 2D",
+		"cross2":             "componente z do produto vetorial de dois vetores 2D",
+		"angle2":             "ângulo com sinal de a para b, em radianos, em ]-pi, pi]",
+		"dot3":               "produto escalar de dois vetores 3D",
+		"cross3":             "norma do produto vetorial de dois vetores 3D",
+		"binom_pmf":          "P(X = k) na binomial de n tentativas com probabilidade p",
+		"binom_cdf":          "P(X <= k) na binomial de n tentativas com probabilidade p",
+		"poisson_pmf":        "P(X = k) na Poisson de média lambda > 0",
+		"normal_pdf":         "densidade da normal de média mu e desvio padrão sigma > 0",
+		"normal_cdf":         "função de distribuição da normal, P(X <= x)",
+		"qnorm":              "inversa de normal_cdf, para 0 < p < 1",
+		"probit":             "o mesmo que qnorm",
+		"chi2_ppf":           "valor crítico do qui-quadrado com df graus de liberdade",
+		"t_ppf":              "valor crítico do t de Student com df graus de liberdade",
+		"ma":                 "média dos últimos window valores",
+		"ema":                "média móvel exponencial, com 0 < alpha <= 1",
+		"bits":               "bits hi a lo de v (0 <= lo <= hi <= 63)",
+		"setbits":            "v com os bits hi a lo substituídos por x",
+		"omega":              "número de fatores primos distintos de n > 0",
+		"bigomega":           "número de fatores primos de n > 0, com multiplicidade",
+		"mobius":             "função de Möbius: 0 se n tem um fator quadrado, senão (-1)^omega(n)",
+		"liouville":          "função de Liouville, (-1)^bigomega(n)",
+		"digitsum":           "soma dos dígitos decimais (n truncado, sem sinal)",
+		"digitalroot":        "soma dos dígitos repetida até ficar um só",
+		"numdigits":          "número de dígitos de n na base dada (2 a 36)",
+		"fmod":               "resto de x/y com o sinal de x, como o fmod do C",
+		"catalan":            "n-ésimo número de Catalan, C(2n,n)/(n+1)",
+		"bell":               "n-ésimo número de Bell (partições de um conjunto de n elementos)",
+		"lucas":              "n-ésimo número de Lucas, L(0) = 2, L(1) = 1",
+		"fib_pair":           "F(n), mostrando também F(n+1)",
+		"fib":                "n-ésimo número de Fibonacci em O(log n); exato com :exact",
+		"sigma":              "soma de expr para x = a, a+1, ..., b (a e b inteiros; 0 se a > b)",
+		"pi_prod":            "produto de expr para x = a, ..., b (1 se a > b)",
+		"verify_identity":    "1 se f e g (expressões em x) coincidem em n pontos aleatórios de [-10, 10], senão 0",
+		"gradient_descent":   "mínimo de f (expressão em x) por descida do gradiente a partir de x0, com taxa lr",
+		"fixed_point":        "ponto fixo x = g(x) (expressão em x), iterando a partir de x0 até |g(x)-x| < tol",
+		"to_rad":             "graus para radianos",
+		"to_deg":             "radianos para graus",
+		"to_grad":            "graus para grados (400 grados = 360°)",
+		"from_grad":          "grados para graus",
+		"complexity":         "custo estimado de avaliar expr (o de :maxcost), sem a avaliar",
+		"cumsum":             "soma dos valores; mostra as somas parciais, uma por linha",
+		"cumprod":            "produto dos valores; mostra os produtos parciais, um por linha",
+		"polyeval":           "polinómio da sessão (:poly ou polyfit) em x",
+		"polyfit":            "ajusta por mínimos quadrados um polinómio aos pontos, guarda-o para polyeval e devolve R²",
+		"interval":           "intervalo [a, b] no modo :interval (fora dele, o ponto médio)",
+		"chebt":              "polinómio de Chebyshev de primeira espécie T_n(x)",
+		"chebu":              "polinómio de Chebyshev de segunda espécie U_n(x)",
+		"legendre":           "polinómio de Legendre P_n(x)",
+		"legendre_roots":     "maior raiz de P_n; mostra as n raízes e os pesos de Gauss-Legendre",
+		"lambertw":           "função W de Lambert (ramo principal): W·e^W = x",
+		"zeta":               "função zeta de Riemann, para s real diferente de 1",
+		"poch":               "fatorial crescente (símbolo de Pochhammer) x(x+1)...(x+n-1); n real via Γ(x+n)/Γ(x)",
+		"fallfact":           "fatorial decrescente x(x-1)...(x-n+1)",
+		"digits":             "mostra n como soma de potências da base (2 a 36): 1×10³ + 2×10² + ...",
+		"phase":              "argumento de re + im·i, na unidade angular atual",
+		"polar":              "módulo de re + im·i; mostra r e θ",
+		"rect":               "parte real de r·e^(iθ); mostra re + im·i",
+		"if":                 "a se c não for zero, senão b; o mesmo que c ? a : b",
+		"number_format":      "mostra x com as casas e os separadores decimal e de milhares dados; devolve x",
+		"bearing_to_math":    "rumo (0 = norte, sentido horário) para ângulo matemático (0 = este, anti-horário), na unidade atual",
+		"math_to_bearing":    "ângulo matemático para rumo, na unidade atual",
+		"normalize_angle":    "reduz o ângulo a [0, 2π), [0, 360) ou [0, 400), conforme a unidade",
+		"solve_quadratic":    "raízes reais de ax² + bx + c = 0; mostra as duas e devolve a maior",
+		"primorial":          "produto dos primos <= n (exato no modo :exact)",
+		"prime_pi":           "número de primos <= n",
+		"integral_approx":    "integral de f (expressão em x ou nome de função) entre a e b; métodos simpson (omissão), trapezoid, midpoint, gauss5",
+		"tabulate":           "mostra a tabela de x e f(x) para x de a a b (f expressão em x ou nome de função); devolve o número de linhas",
+		"to_words":           "mostra n por extenso, em inglês (0 a 999999999999): 42 → forty-two; devolve 0",
+		"roman":              "mostra n (1 a 3999) em numeração romana e devolve-o; :roman XIV faz o inverso",
+		"goldenratio_approx": "fib(n+1)/fib(n), aproximação da razão de ouro φ = (1+√5)/2; com :verbose on mostra a convergência",
+		"prime_factors":      "mostra a fatorização de n em primos, ex.: 2^3 × 3^2 × 5, e devolve n",
+		"prime_factors_list": "número de fatores primos distintos de n (como omega)",
+	},
+	"en": {
+		"sin":                "sine of x (in radians, or degrees/gradians with :deg/:grad)",
+		"cos":                "cosine of x (in radians, or degrees/gradians with :deg/:grad)",
+		"tan":                "tangent of x (in radians, or degrees/gradians with :deg/:grad); huge, not infinite, near pi/2",
+		"sqrt":               "square root; error for x < 0",
+		"log":                "base-10 logarithm; -Inf at 0, NaN for x < 0",
+		"ln":                 "natural logarithm; -Inf at 0, NaN for x < 0",
+		"abs":                "absolute value, also |x|",
+		"floor":              "largest integer <= x",
+		"ceil":               "smallest integer >= x",
+		"round":              "nearest integer; halves round away from zero",
+		"max":                "the larger of the two values",
+		"min":                "the smaller of the two values",
+		"divmod_q":           "floored integer division quotient, floor(a/b)",
+		"divmod_r":           "floored division remainder, with the sign of b",
+		"copysign":           "|x| with the sign of y",
+		"remainder":          "IEEE 754 remainder, in [-y/2, y/2]",
+		"dim":                "positive difference, max(x-y, 0)",
+		"cbrt":               "real cube root (unlike x^(1/3) for x < 0)",
+		"isqrt":              "integer square root, floor(sqrt(n)), for n >= 0",
+		"factorial":          "n!, for integers n >= 0; +Inf above 170 (exact with :exact)",
+		"poly":               "polynomial a0 + a1·x + a2·x² + ... (Horner)",
+		"polyderiv":          "derivative of the poly polynomial, at x",
+		"polyeval_at_roots":  "(x-r1)(x-r2)..., the monic polynomial with those roots",
+		"cf":                 "shows the first n continued fraction terms of x and returns x",
+		"cf_convergent":      "n-th continued fraction convergent p/q of x (p/q with :debug on)",
+		"floorm":             "largest multiple of m <= x (m > 0)",
+		"ceilm":              "smallest multiple of m >= x (m > 0)",
+		"det2":               "determinant of [[a,b],[c,d]]",
+		"det3":               "determinant of the 3×3 matrix given by rows",
+		"trace2":             "trace of [[a,b],[c,d]], a+d",
+		"trace3":             "trace of the 3×3 matrix given by rows",
+		"dot2":               "dot product of two 2D vectors",
+		"cross2":             "z component of the cross product of two 2D vectors",
+		"angle2":             "signed angle from a to b, in radians, in (-pi, pi]",
+		"dot3":               "dot product of two 3D vectors",
+		"cross3":             "magnitude of the cross product of two 3D vectors",
+		"binom_pmf":          "P(X = k) for a binomial with n trials and probability p",
+		"binom_cdf":          "P(X <= k) for a binomial with n trials and probability p",
+		"poisson_pmf":        "P(X = k) for a Poisson with mean lambda > 0",
+		"normal_pdf":         "density of the normal with mean mu and standard deviation sigma > 0",
+		"normal_cdf":         "normal cumulative distribution, P(X <= x)",
+		"qnorm":              "inverse of normal_cdf, for 0 < p < 1",
+		"probit":             "same as qnorm",
+		"chi2_ppf":           "chi-squared critical value with df degrees of freedom",
+		"t_ppf":              "Student's t critical value with df degrees of freedom",
+		"ma":                 "mean of the last window values",
+		"ema":                "exponential moving average, with 0 < alpha <= 1",
+		"bits":               "bits hi down to lo of v (0 <= lo <= hi <= 63)",
+		"setbits":            "v with bits hi down to lo replaced by x",
+		"omega":              "number of distinct prime factors of n > 0",
+		"bigomega":           "number of prime factors of n > 0, with multiplicity",
+		"mobius":             "Möbius function: 0 if n has a square factor, else (-1)^omega(n)",
+		"liouville":          "Liouville function, (-1)^bigomega(n)",
+		"digitsum":           "sum of the decimal digits (n truncated, sign ignored)",
+		"digitalroot":        "digit sum repeated until a single digit is left",
+		"numdigits":          "number of digits of n in the given base (2 to 36)",
+		"fmod":               "remainder of x/y with the sign of x, like C's fmod",
+		"catalan":            "n-th Catalan number, C(2n,n)/(n+1)",
+		"bell":               "n-th Bell number (partitions of an n-element set)",
+		"lucas":              "n-th Lucas number, L(0) = 2, L(1) = 1",
+		"fib_pair":           "F(n), also showing F(n+1)",
+		"fib":                "n-th Fibonacci number in O(log n); exact with :exact",
+		"sigma":              "sum of expr for x = a, a+1, ..., b (integer a and b; 0 if a > b)",
+		"pi_prod":            "product of expr for x = a, ..., b (1 if a > b)",
+		"verify_identity":    "1 if f and g (expressions in x) agree at n random points of [-10, 10], else 0",
+		"gradient_descent":   "minimum of f (an expression in x) by gradient descent from x0 with learning rate lr",
+		"fixed_point":        "fixed point x = g(x) (an expression in x), iterating from x0 until |g(x)-x| < tol",
+		"to_rad":             "degrees to radians",
+		"to_deg":             "radians to degrees",
+		"to_grad":            "degrees to gradians (400 gradians = 360°)",
+		"from_grad":          "gradians to degrees",
+		"complexity":         "estimated cost of evaluating expr (the one :maxcost uses), without evaluating it",
+		"cumsum":             "sum of the values; shows the running sums, one per line",
+		"cumprod":            "product of the values; shows the running products, one per line",
+		"polyeval":           "the session polynomial (:poly or polyfit) at x",
+		"polyfit":            "least-squares fit of a polynomial to the points; stores it for polyeval and returns R²",
+		"interval":           "interval [a, b] in :interval mode (otherwise the midpoint)",
+		"chebt":              "Chebyshev polynomial of the first kind T_n(x)",
+		"chebu":              "Chebyshev polynomial of the second kind U_n(x)",
+		"legendre":           "Legendre polynomial P_n(x)",
+		"legendre_roots":     "largest root of P_n; shows the n roots and the Gauss-Legendre weights",
+		"lambertw":           "Lambert W function (principal branch): W·e^W = x",
+		"zeta":               "Riemann zeta function, for real s other than 1",
+		"poch":               "rising factorial (Pochhammer symbol) x(x+1)...(x+n-1); real n via Γ(x+n)/Γ(x)",
+		"fallfact":           "falling factorial x(x-1)...(x-n+1)",
+		"digits":             "shows n as a sum of powers of the base (2 to 36): 1×10³ + 2×10² + ...",
+		"phase":              "argument of re + im·i, in the current angle unit",
+		"polar":              "modulus of re + im·i; shows r and θ",
+		"rect":               "real part of r·e^(iθ); shows re + im·i",
+		"if":                 "a if c is non-zero, otherwise b; same as c ? a : b",
+		"number_format":      "shows x with the given decimals and decimal and thousands separators; returns x",
+		"bearing_to_math":    "compass bearing (0 = north, clockwise) to mathematical angle (0 = east, counterclockwise), in the current unit",
+		"math_to_bearing":    "mathematical angle to compass bearing, in the current unit",
+		"normalize_angle":    "reduces the angle to [0, 2π), [0, 360) or [0, 400), depending on the unit",
+		"solve_quadratic":    "real roots of ax² + bx + c = 0; shows both and returns the larger",
+		"primorial":          "product of the primes <= n (exact in :exact mode)",
+		"prime_pi":           "number of primes <= n",
+		"integral_approx":    "integral of f (an expression in x or a function name) from a to b; methods simpson (default), trapezoid, midpoint, gauss5",
+		"tabulate":           "shows a table of x and f(x) for x from a to b (f an expression in x or a function name); returns the number of rows",
+		"to_words":           "shows n in words, in English (0 to 999999999999): 42 → forty-two; returns 0",
+		"roman":              "shows n (1 to 3999) in Roman numerals and returns it; :roman XIV does the reverse",
+		"goldenratio_approx": "fib(n+1)/fib(n), an approximation of the golden ratio φ = (1+√5)/2; with :verbose on shows the convergence",
+		"prime_factors":      "shows the prime factorization of n, e.g. 2^3 × 3^2 × 5, and returns n",
+		"prime_factors_list": "number of distinct prime factors of n (like omega)",
+	},
+}
+
+// opDescriptions descreve cada operador, para :operators e :help.
+var opDescriptions = map[string]map[string]string{
+	"pt": {
+		"+":  "soma",
+		"-":  "subtração; antes de um valor, simétrico (-2^2 = -(2^2))",
+		"*":  "multiplicação",
+		"/":  "divisão (inteira com :intmode on)",
+		"//": "divisão inteira, floor(a/b)",
+		"^":  "potência, associativa à direita: 2^3^2 = 2^9; também x², x³",
+		"|":  "ou bit a bit; |x| é o valor absoluto",
+		"±":  "mais ou menos: 1 ± 2 dá dois resultados",
+		"==": "igual: 1 se verdadeiro, 0 se falso",
+		"!=": "diferente: 1 se verdadeiro, 0 se falso",
+		"<":  "menor: 1 se verdadeiro, 0 se falso",
+		"<=": "menor ou igual: 1 se verdadeiro, 0 se falso",
+		">":  "maior: 1 se verdadeiro, 0 se falso",
+		">=": "maior ou igual: 1 se verdadeiro, 0 se falso",
+		"?:": "condicional: a se c for diferente de 0, senão b",
+		"%":  "percentagem, posfixo: 15% = 0.15; só depois de um número ou de um parêntese",
+		"of": "multiplicação de prioridade baixa: 15% of 200 = 30",
+	},
+	"en": {
+		"+":  "addition",
+		"-":  "subtraction; before a value, negation (-2^2 = -(2^2))",
+		"*":  "multiplication",
+		"/":  "division (integer division with :intmode on)",
+		"//": "integer division, floor(a/b)",
+		"^":  "power, right-associative: 2^3^2 = 2^9; also x², x³",
+		"|":  "bitwise or; |x| is the absolute value",
+		"±":  "plus or minus: 1 ± 2 gives two results",
+		"==": "equal: 1 if true, 0 if false",
+		"!=": "not equal: 1 if true, 0 if false",
+		"<":  "less than: 1 if true, 0 if false",
+		"<=": "less than or equal: 1 if true, 0 if false",
+		">":  "greater than: 1 if true, 0 if false",
+		">=": "greater than or equal: 1 if true, 0 if false",
+		"?:": "conditional: a if c is not 0, otherwise b",
+		"%":  "percent, postfix: 15% = 0.15; only after a number or a closing parenthesis",
+		"of": "low-precedence multiplication: 15% of 200 = 30",
+	},
+}
+
+// constDescriptions descreve cada constante de constants.
+var constDescriptions = map[string]map[string]string{
+	"pt": {
+		"pi": "razão entre o perímetro e o diâmetro de um círculo",
+		"e":  "base dos logaritmos naturais",
+	},
+	"en": {
+		"pi": "ratio of a circle's circumference to its diameter",
+		"e":  "base of the natural logarithm",
+	},
+}
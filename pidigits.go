@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// :pi N — π com N casas decimais pelo algoritmo de Chudnovsky, com a série
+// somada por divisão binária (binary splitting) em big.Int:
+//
+//	1/π = 12 Σ (-1)^k (6k)! (13591409 + 545140134k) / ((3k)! (k!)³ 640320^(3k+3/2))
+
+const maxPiDigits = 100000
+
+// chudnovskyC3 é 640320³/24.
+var chudnovskyC3 = big.NewInt(10939058860032000)
+
+// piSplit calcula P, Q e T da série para os termos [a, b).
+func piSplit(a, b int64) (p, q, t *big.Int) {
+	if b-a == 1 {
+		if a == 0 {
+			p, q = big.NewInt(1), big.NewInt(1)
+		} else {
+			p = big.NewInt(6*a - 5)
+			p.Mul(p, big.NewInt(2*a-1))
+			p.Mul(p, big.NewInt(6*a-1))
+			p.Neg(p)
+			q = big.NewInt(a)
+			q.Mul(q, q).Mul(q, big.NewInt(a)).Mul(q, chudnovskyC3)
+		}
+		t = big.NewInt(545140134)
+		t.Mul(t, big.NewInt(a)).Add(t, big.NewInt(13591409)).Mul(t, p)
+		return p, q, t
+	}
+	m := (a + b) / 2
+	p1, q1, t1 := piSplit(a, m)
+	p2, q2, t2 := piSplit(m, b)
+	t = new(big.Int).Mul(t1, q2)
+	t.Add(t, new(big.Int).Mul(p1, t2))
+	return p1.Mul(p1, p2), q1.Mul(q1, q2), t
+}
+
+// piDigits devolve π com n casas decimais (truncado), ex.: "3.1415926535".
+func piDigits(n int) string {
+	terms := int64(n)/14 + 2 // cada termo acrescenta ~14,18 dígitos
+	prec := uint(float64(n+20)*math.Log2(10)) + 64
+	_, q, t := piSplit(0, terms)
+	sq := new(big.Float).SetPrec(prec).SetInt64(10005)
+	sq.Sqrt(sq)
+	num := new(big.Float).SetPrec(prec).SetInt(q)
+	num.Mul(num, sq).Mul(num, new(big.Float).SetPrec(prec).SetInt64(426880))
+	pi := num.Quo(num, new(big.Float).SetPrec(prec).SetInt(t))
+	s := pi.Text('f', n+10)
+	return s[:len("3.")+n]
+}
+
+// printPi mostra π com as casas pedidas, em grupos de 10 dígitos (50 por
+// linha). Se o cálculo demorar mais de meio segundo, avisa que está a decorrer.
+func printPi(out io.Writer, arg string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 1 || n > maxPiDigits {
+		return fmt.Errorf(msg("usage_pi"), maxPiDigits)
+	}
+	done := make(chan string, 1)
+	start := time.Now()
+	go func() { done <- piDigits(n) }()
+	var digits string
+	select {
+	case digits = <-done:
+	case <-time.After(500 * time.Millisecond):
+		fmt.Fprintf(out, msg("pi_progress")+"\n", n)
+		digits = <-done
+		fmt.Fprintf(out, msg("pi_done")+"\n", time.Since(start).Seconds())
+	}
+	fmt.Fprintln(out, "3.")
+	decimals := digits[2:]
+	for i := 0; i < len(decimals); i += 50 {
+		line := decimals[i:min(i+50, len(decimals))]
+		var groups []string
+		for j := 0; j < len(line); j += 10 {
+			groups = append(groups, line[j:min(j+10, len(line))])
+		}
+		fmt.Fprintln(out, "  "+strings.Join(groups, " "))
+	}
+	return nil
+}
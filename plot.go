@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// :graph expr from a to b — gráfico de dispersão em ASCII de uma expressão
+// em x (ou noutra variável, com "expr for t from a to b").
+
+const (
+	graphWidth  = 60
+	graphHeight = 20
+)
+
+var graphRe = regexp.MustCompile(`(?i)^(.+?)\s+(from\s+.+)$`)
+
+// plot amostra a expressão em width pontos de [r.from, r.to] e devolve o
+// gráfico com height linhas, o eixo y ajustado ao intervalo dos valores.
+// Os pontos onde a expressão não é finita ficam em branco.
+func plot(r rangeSpec, ctx *EvalContext, width, height int) (string, error) {
+	r.step = (r.to - r.from) / float64(width-1)
+	if r.step <= 0 {
+		return "", errors.New(msg("range_step"))
+	}
+	ys := make([]float64, 0, width)
+	err := evalRange(r, ctx, func(_, y float64) { ys = append(ys, y) })
+	if err != nil {
+		return "", err
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, y := range ys {
+		if !math.IsInf(y, 0) && !math.IsNaN(y) {
+			lo, hi = math.Min(lo, y), math.Max(hi, y)
+		}
+	}
+	if lo > hi {
+		return "", errors.New(msg("non_finite"))
+	}
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	row := func(y float64) int { return int(math.Round((hi - y) / (hi - lo) * float64(height-1))) }
+
+	grid := make([][]byte, height)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", width))
+	}
+	if lo < 0 && hi > 0 {
+		copy(grid[row(0)], strings.Repeat("-", width))
+	}
+	if r.from < 0 && r.to > 0 {
+		c := int(math.Round(-r.from / r.step))
+		for i := range grid {
+			grid[i][c] = '|'
+		}
+	}
+	for c, y := range ys {
+		if c < width && !math.IsInf(y, 0) && !math.IsNaN(y) {
+			grid[row(y)][c] = '*'
+		}
+	}
+
+	// marcas nos extremos e no zero (ou, se o zero não estiver à vista, a meio)
+	label := func(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+	yLabels := map[int]string{0: label(hi), height - 1: label(lo)}
+	if lo < 0 && hi > 0 {
+		yLabels[row(0)] = "0"
+	} else {
+		mid := (height - 1) / 2
+		yLabels[mid] = label(hi - (hi-lo)*float64(mid)/float64(height-1))
+	}
+	lw := 0
+	for _, l := range yLabels {
+		lw = max(lw, len(l))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s  %s, %s = %s .. %s\n", lw, "", strings.TrimSpace(r.expr), r.variable, label(r.from), label(r.to))
+	for i, line := range grid {
+		tick := byte('|')
+		if _, ok := yLabels[i]; ok {
+			tick = '+'
+		}
+		fmt.Fprintf(&b, "%*s %c%s\n", lw, yLabels[i], tick, strings.TrimRight(string(line), " "))
+	}
+	mid := (width - 1) / 2
+	midVal := label(r.from + float64(mid)*r.step)
+	if r.from < 0 && r.to > 0 {
+		mid, midVal = int(math.Round(-r.from/r.step)), "0"
+	}
+	axis := []byte("+" + strings.Repeat("-", width))
+	for _, c := range []int{0, mid, width - 1} {
+		axis[c+1] = '+'
+	}
+	fmt.Fprintf(&b, "%*s %s\n", lw, "", axis)
+	xl := []byte(strings.Repeat(" ", width+lw+2))
+	for _, t := range []struct {
+		c int
+		l string
+	}{{0, label(r.from)}, {mid, midVal}, {width - 1, label(r.to)}} {
+		start := min(max(lw+2+t.c-len(t.l)/2, 0), len(xl)-len(t.l))
+		copy(xl[start:], t.l)
+	}
+	b.WriteString(strings.TrimRight(string(xl), " "))
+	return b.String(), nil
+}
+
+// printGraph mostra o gráfico de :graph, com a largura limitada pela do
+// terminal.
+func (s *Session) printGraph(spec string) error {
+	if !rangeRe.MatchString(strings.TrimSpace(spec)) {
+		spec = graphRe.ReplaceAllString(strings.TrimSpace(spec), "$1 for x $2")
+	}
+	r, err := parseRange(spec, s.ctx)
+	if err != nil {
+		return errors.New(msg("usage_graph"))
+	}
+	out, err := plot(r, s.ctx, max(min(graphWidth, terminalWidth()-12), 10), graphHeight)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, out)
+	return nil
+}
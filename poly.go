@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Polinómio da sessão: definido com :poly a0 a1 ... ou ajustado a pontos com
+// polyfit, e avaliado com polyeval(x) ou, no REPL, com cada x = ...
+
+// maxFitDegree limita o grau de polyfit; acima disto as equações normais
+// ficam mal condicionadas.
+const maxFitDegree = 10
+
+// horner avalia a0 + a1·x + ... + an·x^n pelo método de Horner.
+func horner(x float64, c []float64) float64 {
+	res := 0.0
+	for i := len(c) - 1; i >= 0; i-- {
+		res = res*x + c[i]
+	}
+	return res
+}
+
+// formatPoly escreve o polinómio, ex.: "1 + 2·x + x^2".
+func formatPoly(c []float64) string {
+	var terms []string
+	for k, a := range c {
+		if a == 0 && len(c) > 1 {
+			continue
+		}
+		coef := strconv.FormatFloat(a, 'g', 15, 64)
+		switch {
+		case k == 0:
+			terms = append(terms, coef)
+		case a == 1:
+			terms = append(terms, "x"+powerSuffix(k))
+		case a == -1:
+			terms = append(terms, "-x"+powerSuffix(k))
+		default:
+			terms = append(terms, coef+"·x"+powerSuffix(k))
+		}
+	}
+	if len(terms) == 0 {
+		return "0"
+	}
+	return strings.ReplaceAll(strings.Join(terms, " + "), "+ -", "- ")
+}
+
+func powerSuffix(k int) string {
+	if k == 1 {
+		return ""
+	}
+	return "^" + strconv.Itoa(k)
+}
+
+// polyFit ajusta por mínimos quadrados um polinómio do grau pedido aos pontos
+// (x1, y1), ..., (xn, yn). Os coeficientes ficam na sessão, para polyeval, e
+// aparecem como nota; devolve o coeficiente de determinação R².
+func polyFit(ctx *EvalContext, a ...float64) (float64, error) {
+	if len(a)%2 == 0 {
+		return 0, errors.New(msg("polyfit_args"))
+	}
+	deg, err := asInt("polyfit", a[len(a)-1])
+	if err != nil {
+		return 0, err
+	}
+	pts := a[:len(a)-1]
+	n := len(pts) / 2
+	if deg < 0 || deg > maxFitDegree {
+		return 0, fmt.Errorf(msg("polyfit_degree"), maxFitDegree)
+	}
+	m := int(deg) + 1
+	if n < m {
+		return 0, fmt.Errorf(msg("polyfit_points"), m)
+	}
+	// equações normais (VᵀV) c = Vᵀy, com V a matriz de Vandermonde
+	ata := make([][]float64, m)
+	for i := range ata {
+		ata[i] = make([]float64, m+1)
+	}
+	for p := 0; p < n; p++ {
+		x, y := pts[2*p], pts[2*p+1]
+		pow := make([]float64, 2*m)
+		pow[0] = 1
+		for k := 1; k < len(pow); k++ {
+			pow[k] = pow[k-1] * x
+		}
+		for i := 0; i < m; i++ {
+			for j := 0; j < m; j++ {
+				ata[i][j] += pow[i+j]
+			}
+			ata[i][m] += pow[i] * y
+		}
+	}
+	c, err := solveLinear(ata)
+	if err != nil {
+		return 0, err
+	}
+	mean := 0.0
+	for p := 0; p < n; p++ {
+		mean += pts[2*p+1] / float64(n)
+	}
+	var ssRes, ssTot float64
+	for p := 0; p < n; p++ {
+		x, y := pts[2*p], pts[2*p+1]
+		ssRes += (y - horner(x, c)) * (y - horner(x, c))
+		ssTot += (y - mean) * (y - mean)
+	}
+	ctx.poly = c
+	ctx.invalidateCache()
+	ctx.note("p(x) = %s", formatPoly(c))
+	if ssTot == 0 {
+		return 1, nil
+	}
+	return 1 - ssRes/ssTot, nil
+}
+
+// solveLinear resolve o sistema da matriz aumentada [A | b] por eliminação de
+// Gauss com pivotagem parcial.
+func solveLinear(m [][]float64) ([]float64, error) {
+	n := len(m)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, errors.New(msg("polyfit_singular"))
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for r := col + 1; r < n; r++ {
+			f := m[r][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[r][k] -= f * m[col][k]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		sum := m[r][n]
+		for k := r + 1; k < n; k++ {
+			sum -= m[r][k] * x[k]
+		}
+		x[r] = sum / m[r][r]
+	}
+	return x, nil
+}
+
+// setPoly trata :poly a0 a1 ... (define), :poly off (apaga) e :poly (mostra).
+func (s *Session) setPoly(arg string) error {
+	switch strings.ToLower(arg) {
+	case "":
+		if s.ctx.poly == nil {
+			return errors.New(msg("no_poly"))
+		}
+		fmt.Fprintln(s.out, "p(x) =", formatPoly(s.ctx.poly))
+		return nil
+	case "off":
+		s.ctx.poly = nil
+		s.ctx.invalidateCache()
+		return nil
+	}
+	var c []float64
+	for _, f := range strings.Fields(arg) {
+		v, err := evalExpr(f, s.ctx)
+		if err != nil {
+			return err
+		}
+		c = append(c, v)
+	}
+	s.ctx.poly = c
+	s.ctx.invalidateCache()
+	fmt.Fprintln(s.out, "p(x) =", formatPoly(c))
+	return nil
+}
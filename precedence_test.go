@@ -0,0 +1,235 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Precedência e associatividade do shunting-yard. Cada caso diz que regra da
+// gramática exercita; a tabela de ops em Calculator.go é a referência:
+//
+//	==  !=           -2  (esquerda)
+//	<  <=  >  >=     -1  (esquerda)
+//	|  of             0  (esquerda)
+//	+  -              1  (esquerda)
+//	*  /  //          2  (esquerda)
+//	-x  +x            3  (prefixos, ligam menos do que ^)
+//	^                 4  (direita)
+//	x%                5  (posfixo)
+//
+// e, abaixo de todos, o condicional c ? a : b, associativo à direita.
+
+type precedenceCase struct {
+	expr string
+	want float64
+	rule string
+}
+
+var precedenceCases = []precedenceCase{
+	// ^ é associativo à direita
+	{"2^3^2", 512, "^ associa à direita: 2^(3^2)"},
+	{"(2^3)^2", 64, "parênteses forçam a associação à esquerda"},
+	{"2^2^3", 256, "^ associa à direita: 2^(2^3)"},
+	{"2^1^5", 2, "^ associa à direita: 2^(1^5)"},
+	{"3^2^0", 3, "^ associa à direita: 3^(2^0)"},
+	{"4^0.5^2", math.Pow(4, 0.25), "^ associa à direita com expoentes decimais"},
+	{"2^3^2^0", 8, "^ associa à direita em cadeias de quatro"},
+
+	// o menos unário liga menos do que ^
+	{"-2^2", -4, "-x^y é -(x^y)"},
+	{"(-2)^2", 4, "parênteses põem o menos dentro da base"},
+	{"-2^3", -8, "-x^y é -(x^y) com expoente ímpar"},
+	{"-3^2", -9, "-x^y é -(x^y)"},
+	{"2^-1", 0.5, "menos unário no expoente: 2^(-1)"},
+	{"2^-2^2", 1.0 / 16, "menos unário no expoente, ^ à direita: 2^(-(2^2))"},
+	{"-2^-2", -0.25, "menos unário na base e no expoente: -(2^(-2))"},
+	{"2^+3", 8, "mais unário no expoente"},
+	{"-2^2^3", -256, "-x^y^z é -(x^(y^z))"},
+	{"10^-3", 0.001, "expoente negativo sem parênteses"},
+
+	// unários encadeados e misturados com binários
+	{"--1", 1, "dois menos unários anulam-se"},
+	{"---1", -1, "três menos unários"},
+	{"-+1", -1, "menos e mais unários"},
+	{"+-1", -1, "mais e menos unários"},
+	{"++1", 1, "dois mais unários"},
+	{"1--1", 2, "binário seguido de unário: 1 - (-1)"},
+	{"1-+1", 0, "binário seguido de mais unário"},
+	{"1+-1", 0, "mais binário seguido de menos unário"},
+	{"1---1", 0, "binário seguido de dois unários: 1 - (-(-1))"},
+	{"2*-3", -6, "unário depois de *"},
+	{"6/-2", -3, "unário depois de /"},
+	{"-2*-3", 6, "unários nos dois operandos de *"},
+	{"-(2+3)", -5, "menos unário antes de parênteses"},
+	{"-(-(2))", 2, "menos unário dentro e fora de parênteses"},
+	{"-2*3", -6, "-x*y: o menos liga mais do que *"},
+	{"-6/2", -3, "-x/y: o menos liga mais do que /"},
+	{"-2+3", 1, "-x+y: o menos liga mais do que +"},
+	{"3*-2^2", -12, "unário entre * e ^: 3 * (-(2^2))"},
+
+	// * e / ligam mais do que + e -
+	{"2*3+4*5", 26, "* antes de +"},
+	{"2+3*4", 14, "* antes de +, à direita"},
+	{"2*3+4", 10, "* antes de +, à esquerda"},
+	{"10-2*3", 4, "* antes de -"},
+	{"10-6/2", 7, "/ antes de -"},
+	{"1+2*3-4/2", 5, "* e / antes de + e -"},
+	{"(2+3)*4", 20, "parênteses antes de *"},
+	{"2*(3+4)", 14, "parênteses no operando direito de *"},
+	{"2+3^2", 11, "^ antes de +"},
+	{"2*3^2", 18, "^ antes de *"},
+	{"(2*3)^2", 36, "parênteses antes de ^"},
+	{"2^3*2", 16, "^ antes de * à esquerda"},
+	{"12/2^2", 3, "^ antes de /"},
+
+	// associatividade à esquerda de + - * / //
+	{"10-2-3", 5, "- associa à esquerda: (10-2)-3"},
+	{"10-(2-3)", 11, "parênteses mudam a associação de -"},
+	{"100/10/5", 2, "/ associa à esquerda: (100/10)/5"},
+	{"100/(10/5)", 50, "parênteses mudam a associação de /"},
+	{"2*3/4", 1.5, "* e / com a mesma precedência, da esquerda"},
+	{"8/4*2", 4, "/ e * com a mesma precedência, da esquerda"},
+	{"1-2+3", 2, "- e + com a mesma precedência, da esquerda"},
+	{"1+2-3", 0, "+ e - com a mesma precedência, da esquerda"},
+	{"7//2*2", 6, "// e * com a mesma precedência: (7//2)*2"},
+	{"2*7//4", 3, "* e // com a mesma precedência: (2*7)//4"},
+	{"9//2//2", 2, "// associa à esquerda"},
+	{"1+7//2", 4, "// antes de +"},
+	{"-7//2", -4, "menos unário antes de //: (-7)//2"},
+
+	// percentagem posfixa
+	{"50%", 0.5, "x% é x/100"},
+	{"2^10%", math.Pow(2, 0.1), "% liga mais do que ^: 2^(10%)"},
+	{"-3%", -0.03, "% liga mais do que o menos unário"},
+	{"200*15%", 30, "% antes de *"},
+	{"1+50%", 1.5, "% antes de +"},
+	{"(10+20)%", 0.3, "% depois de parênteses"},
+	{"50%*2", 1, "% seguido de um operador binário"},
+	{"20% of 50", 10, "of multiplica"},
+	{"1+1 of 10", 20, "of liga menos do que +: (1+1) of 10"},
+	{"10% of 100+100", 20, "of liga menos do que +: 10% of (100+100)"},
+	{"2*3 of 4", 24, "of liga menos do que *"},
+
+	// comparações
+	{"1+1 == 2", 1, "+ antes de =="},
+	{"2*3 == 6", 1, "* antes de =="},
+	{"1 < 2", 1, "comparação verdadeira vale 1"},
+	{"2 < 1", 0, "comparação falsa vale 0"},
+	{"1 < 2 == 1", 1, "< antes de ==: (1<2) == 1"},
+	{"1 == 1 < 2", 1, "< antes de ==: 1 == (1<2)"},
+	{"3 > 2 > 1", 0, "> associa à esquerda: (3>2) > 1"},
+	{"1 != 2", 1, "!= entre números diferentes"},
+	{"2^2 >= 4", 1, "^ antes de >="},
+	{"-1 <= 0", 1, "menos unário antes de <="},
+	{"1+2 > 2", 1, "+ antes de >"},
+	{"2 == 2 != 0", 1, "== e != associam à esquerda"},
+	{"5 | 2", 7, "| é o ou bit a bit"},
+	{"1+2 | 4", 7, "| liga menos do que +"},
+	{"4 | 1 == 5", 1, "| liga mais do que =="},
+
+	// condicional c ? a : b
+	{"1 ? 2 : 3", 2, "condição verdadeira escolhe o primeiro ramo"},
+	{"0 ? 2 : 3", 3, "condição falsa escolhe o segundo ramo"},
+	{"1 ? 2 : 3 ? 4 : 5", 2, "?: associa à direita"},
+	{"0 ? 2 : 0 ? 4 : 5", 5, "?: associa à direita: 0 ? 2 : (0 ? 4 : 5)"},
+	{"0 ? 2 : 1 ? 4 : 5", 4, "?: associa à direita, ramo do meio"},
+	{"1 < 2 ? 10 : 20", 10, "a comparação liga mais do que ?"},
+	{"1+1 ? 3 : 4", 3, "+ liga mais do que ?"},
+	{"0 ? 1 : 2+3", 5, "o ramo falso estende-se até ao fim"},
+	{"1 ? 2+3 : 0", 5, "o ramo verdadeiro vai até ao :"},
+	{"(1 ? 2 : 3)*10", 20, "parênteses limitam o condicional"},
+	{"1 ? (0 ? 1 : 2) : 3", 2, "condicional dentro de um ramo"},
+
+	// chamadas de funções
+	{"sin(cos(0))", math.Sin(1), "chamadas encadeadas"},
+	{"sqrt(sqrt(16))", 2, "a mesma função encadeada"},
+	{"abs(-2)^2", 4, "a chamada é um átomo: ^ aplica-se ao resultado"},
+	{"-abs(-2)", -2, "menos unário antes de uma chamada"},
+	{"max(1, 2)*3", 6, "a chamada liga mais do que *"},
+	{"max(1+1, 3*1)", 3, "expressões como argumentos"},
+	{"max(min(1, 2), min(3, 4))", 3, "chamadas como argumentos"},
+	{"sqrt(4)+sqrt(9)", 5, "duas chamadas somadas"},
+	{"2^sqrt(4)", 4, "chamada no expoente"},
+	{"sqrt(2^2^2)", 4, "^ à direita dentro de um argumento"},
+	{"max(-1, -2)", -1, "menos unário no início de cada argumento"},
+	{"if(1 < 2, 10, 20)", 10, "comparação como argumento"},
+	{"floor(-2.5)", -3, "menos unário dentro de uma chamada"},
+
+	// parênteses
+	{"((((1))))", 1, "parênteses encaixados"},
+	{"(((2+3)))*2", 10, "parênteses encaixados antes de *"},
+	{"((2))^((3))", 8, "parênteses nos dois operandos de ^"},
+	{"(1)", 1, "um número entre parênteses"},
+	{"-(((3)))", -3, "menos unário antes de parênteses encaixados"},
+	{"((1+2)*(3+4))", 21, "grupos lado a lado dentro de outro"},
+
+	// valor absoluto |x|
+	{"|-3|", 3, "|x| é o valor absoluto"},
+	{"|-2|^2", 4, "|x| é um átomo: ^ aplica-se ao resultado"},
+	{"|2-5|*2", 6, "expressão dentro de |...|"},
+	{"-|-3|", -3, "menos unário antes de |x|"},
+
+	// expoentes em sobrescrito
+	{"2²", 4, "x² é x^2"},
+	{"-2²", -4, "-x² é -(x²), como -x^2"},
+	{"2²³", 1 << 23, "sobrescritos seguidos formam um só expoente"},
+	{"3²+4²", 25, "sobrescrito antes de +"},
+	{"(1+1)³", 8, "sobrescrito depois de parênteses"},
+
+	// espaços e números
+	{"  2 +   3 ", 5, "os espaços não contam"},
+	{"1_000 + 1", 1001, "separador de dígitos"},
+	{"1e3*2", 2000, "notação científica como um só número"},
+	{"2e-1+1", 1.2, "o menos do expoente pertence ao número"},
+	{".5*4", 2, "número começado por ponto"},
+	{"0x10+1", 17, "literal hexadecimal"},
+}
+
+func TestPrecedence(t *testing.T) {
+	if len(precedenceCases) < 100 {
+		t.Fatalf("só %d casos", len(precedenceCases))
+	}
+	for _, tc := range precedenceCases {
+		got, err := evalExpr(tc.expr, &EvalContext{vars: map[string]float64{}})
+		if err != nil {
+			t.Errorf("%s (%s): %v", tc.expr, tc.rule, err)
+			continue
+		}
+		if math.Abs(got-tc.want) > 1e-12*math.Max(1, math.Abs(tc.want)) {
+			t.Errorf("%s = %v, want %v (%s)", tc.expr, got, tc.want, tc.rule)
+		}
+	}
+}
+
+// Expressões que a gramática não aceita: não há multiplicação implícita, e
+// os operadores e os parênteses têm de estar completos.
+var precedenceErrors = []struct {
+	expr string
+	rule string
+}{
+	{"2pi", "não há multiplicação implícita entre número e constante"},
+	{"2(3)", "não há multiplicação implícita antes de parênteses"},
+	{"(2)(3)", "não há multiplicação implícita entre parênteses"},
+	{"2 sin(0)", "não há multiplicação implícita antes de uma chamada"},
+	{"2 3", "dois números seguidos não se multiplicam"},
+	{"2+", "binário sem operando direito"},
+	{"*2", "binário sem operando esquerdo"},
+	{"2*/3", "dois binários seguidos"},
+	{"(1+2", "parêntese por fechar"},
+	{"1+2)", "parêntese por abrir"},
+	{"()", "parênteses vazios"},
+	{"1 ? 2", "? sem :"},
+	{"1 : 2", ": sem ?"},
+	{"%5", "% é posfixo: não pode vir antes do operando"},
+	{"²", "sobrescrito sem base"},
+	{"max(1, 2", "chamada por fechar"},
+	{"1, 2", "vírgula fora de uma chamada"},
+}
+
+func TestPrecedenceErrors(t *testing.T) {
+	for _, tc := range precedenceErrors {
+		if got, err := evalExpr(tc.expr, &EvalContext{vars: map[string]float64{}}); err == nil {
+			t.Errorf("%s = %v, want an error (%s)", tc.expr, got, tc.rule)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// O condicional volta a escrever-se c ? a : b, com parênteses só onde
+// mudariam o agrupamento; um if(c, a, b) escrito como chamada fica como está.
+func TestPrettyPrintTernary(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"x > 0 ? sqrt(x) : 0", "x > 0 ? sqrt(x) : 0"},
+		{"a ? b : c ? d : e", "a ? b : c ? d : e"},
+		{"a ? b ? c : d : e", "a ? b ? c : d : e"},
+		{"(a ? b : c) ? d : e", "(a ? b : c) ? d : e"},
+		{"2*(a?1:2)+1", "2 * (a ? 1 : 2) + 1"},
+		{"-(a?b:c)", "-(a ? b : c)"},
+		{"max(a ? 1 : 2, 3)", "max(a ? 1 : 2, 3)"},
+		{"if(a, b, c)", "if(a, b, c)"},
+	}
+	for _, tc := range tests {
+		toks, err := tokenize(tc.in, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.in, err)
+		}
+		if got := PrettyPrint(toks); got != tc.want {
+			t.Errorf("PrettyPrint(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// A forma canónica de :pretty tem de ser lida de volta e dar o mesmo valor:
+// PrettyPrint(tokenize(s)) = s' com evalExpr(s) == evalExpr(s'), para as
+// expressões de :test e de precedence_test.go.
+func TestPrettyPrintRoundTrip(t *testing.T) {
+	var exprs []string
+	for _, tc := range selfTests {
+		exprs = append(exprs, tc.expr)
+	}
+	for _, tc := range precedenceCases {
+		exprs = append(exprs, tc.expr)
+	}
+	for _, s := range exprs {
+		toks := mustTokenize(t, s)
+		pretty := PrettyPrint(toks)
+		// sem recorrer aos tokens separados por espaços
+		if rpn, err := shuntingYard(toks, nil); err != nil {
+			t.Errorf("%s: %v", s, err)
+		} else if _, err := RPNToInfix(rpn); err != nil {
+			t.Errorf("%s: RPNToInfix: %v", s, err)
+		}
+		if again := PrettyPrint(mustTokenize(t, pretty)); again != pretty {
+			t.Errorf("%s → %s → %s: a forma canónica mudou", s, pretty, again)
+		}
+		want, err := evalExpr(s, &EvalContext{vars: map[string]float64{}})
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		got, err := evalExpr(pretty, &EvalContext{vars: map[string]float64{}})
+		if err != nil {
+			t.Errorf("%s → %s: %v", s, pretty, err)
+			continue
+		}
+		if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+			t.Errorf("%s = %v, mas %s = %v", s, want, pretty, got)
+		}
+	}
+}
+
+func mustTokenize(t *testing.T, s string) []token {
+	t.Helper()
+	toks, err := tokenize(s, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return toks
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// :profile on — conta as chamadas de cada função e operador em evalRPN e o
+// tempo gasto nelas. O tempo de funções como sigma inclui o das chamadas
+// que fazem.
+
+// Profile acumula as chamadas por nome; nil quando o perfil está desligado.
+type Profile map[string]*profileEntry
+
+type profileEntry struct {
+	count int
+	total time.Duration
+}
+
+// start devolve o instante de início de uma chamada (zero se desligado, para
+// não pagar time.Now fora do modo :profile).
+func (p Profile) start() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// record conta uma chamada a name iniciada em start.
+func (p Profile) record(name string, start time.Time) {
+	if p == nil {
+		return
+	}
+	e, ok := p[name]
+	if !ok {
+		e = &profileEntry{}
+		p[name] = e
+	}
+	e.count++
+	e.total += time.Since(start)
+}
+
+// print mostra a tabela, das mais chamadas para as menos.
+func (p Profile) print(out io.Writer) {
+	if len(p) == 0 {
+		fmt.Fprintln(out, msg("profile_empty"))
+		return
+	}
+	names := make([]string, 0, len(p))
+	w := len(msg("profile_name"))
+	for n := range p {
+		names = append(names, n)
+		w = max(w, len(n))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := p[names[i]], p[names[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return names[i] < names[j]
+	})
+	header := fmt.Sprintf("  %-*s  %10s  %12s", w, msg("profile_name"), msg("profile_count"), msg("profile_time"))
+	fmt.Fprintln(out, header)
+	fmt.Fprintln(out, "  "+strings.Repeat("-", len([]rune(header))-2))
+	for _, n := range names {
+		fmt.Fprintf(out, "  %-*s  %10d  %12s\n", w, n, p[n].count, p[n].total.Round(time.Microsecond))
+	}
+}
+
+// timingRuns é o número de repetições com que se mede uma expressão rápida.
+const timingRuns = 1000
+
+// averageTime devolve o tempo de avaliação de uma linha para :time. Abaixo de
+// 1µs a medição de uma só avaliação é sobretudo ruído, por isso a forma
+// pós-fixa é avaliada timingRuns vezes, numa cópia do contexto, e
+// devolve-se a média.
+func (s *Session) averageTime(line string, elapsed time.Duration) time.Duration {
+	if elapsed >= time.Microsecond {
+		return elapsed
+	}
+	rpns, err := compileAll(line, s.ctx)
+	if err != nil {
+		return elapsed
+	}
+	ctx := *s.ctx
+	ctx.cache, ctx.profile, ctx.verbose = nil, nil, false
+	start := time.Now()
+	for i := 0; i < timingRuns; i++ {
+		for _, rpn := range rpns {
+			ctx.notes = nil
+			evalRPN(rpn, &ctx)
+		}
+	}
+	return time.Since(start) / timingRuns
+}
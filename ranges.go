@@ -0,0 +1,460 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRangeSteps limita o número de avaliações de um intervalo.
+const maxRangeSteps = 100000
+
+// rangeSpec descreve "expr for x from a to b step s".
+type rangeSpec struct {
+	expr     string
+	variable string
+	from, to float64
+	step     float64
+}
+
+var rangeRe = regexp.MustCompile(`(?i)^(.+?)\s+for\s+([\pL_][\pL\pN_]*)\s+from\s+(.+?)\s+to\s+(.+?)(?:\s+step\s+(.+))?$`)
+
+// parseRange lê "expr for x from a to b [step s]"; os limites e o passo
+// podem ser expressões (ex.: from -pi to pi step pi/4).
+func parseRange(spec string, ctx *EvalContext) (rangeSpec, error) {
+	m := rangeRe.FindStringSubmatch(strings.TrimSpace(spec))
+	if m == nil {
+		return rangeSpec{}, errors.New(msg("usage_range"))
+	}
+	r := rangeSpec{expr: m[1], variable: strings.ToLower(m[2]), step: 1}
+	bounds := []struct {
+		src string
+		dst *float64
+	}{{m[3], &r.from}, {m[4], &r.to}, {m[5], &r.step}}
+	for _, b := range bounds {
+		if b.src == "" {
+			continue
+		}
+		v, err := evalExpr(b.src, ctx)
+		if err != nil {
+			return rangeSpec{}, err
+		}
+		*b.dst = v
+	}
+	if r.step == 0 || (r.to-r.from)/r.step < 0 || math.IsNaN(r.step) {
+		return rangeSpec{}, errors.New(msg("range_step"))
+	}
+	if _, ok := constants[r.variable]; ok || r.variable == "ans" {
+		return rangeSpec{}, fmt.Errorf(msg("range_var"), r.variable)
+	}
+	return r, nil
+}
+
+// steps devolve o número de pontos do intervalo, (b-a)/s + 1.
+func (r rangeSpec) steps() int {
+	return int(math.Floor((r.to-r.from)/r.step+1e-9)) + 1
+}
+
+// evalRange avalia a expressão em cada ponto do intervalo, com a variável
+// temporariamente ligada a esse ponto; no fim a variável volta ao que era.
+func evalRange(r rangeSpec, ctx *EvalContext, visit func(x, y float64)) error {
+	n := r.steps()
+	if n > maxRangeSteps {
+		return fmt.Errorf(msg("range_too_long"), n, maxRangeSteps)
+	}
+	rpn, err := compile(r.expr, ctx)
+	if err != nil {
+		return err
+	}
+	restore, err := bindVar(ctx, r.variable)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	for i := 0; i < n; i++ {
+		x := r.from + float64(i)*r.step
+		ctx.vars[r.variable] = x
+		y, err := evalRPN(rpn, ctx)
+		if err != nil {
+			return fmt.Errorf("%s = %g: %w", r.variable, x, err)
+		}
+		visit(x, y)
+		ctx.watch.update(ctx, false)
+	}
+	ctx.watch.finish(ctx)
+	return nil
+}
+
+// printRangeTable mostra "eval expr for x from a to b" em duas colunas.
+func (s *Session) printRangeTable(spec string) error {
+	r, err := parseRange(spec, s.ctx)
+	if err != nil {
+		return err
+	}
+	var xs, ys []string
+	err = evalRange(r, s.ctx, func(x, y float64) {
+		xs = append(xs, s.formatValue(x))
+		ys = append(ys, s.formatValue(y))
+		s.logged = append(s.logged, y)
+	})
+	if err != nil {
+		return err
+	}
+	w := len(r.variable)
+	for _, x := range xs {
+		w = max(w, len(x))
+	}
+	fmt.Fprintf(s.out, "%*s  %s\n", w, r.variable, strings.TrimSpace(r.expr))
+	for i := range xs {
+		fmt.Fprintf(s.out, "%*s  %s\n", w, xs[i], ys[i])
+	}
+	return nil
+}
+
+// tabulate mostra a tabela de tabulate(f, a, b, passo) — x e f(x) para x de
+// a a b — e devolve o número de linhas. f é uma expressão em x ou o nome de
+// uma função, como em integral_approx.
+func tabulate(t token, args []float64, ctx *EvalContext) (float64, error) {
+	r := rangeSpec{variable: "x", from: args[0], to: args[1], step: args[2]}
+	if !(r.step > 0) {
+		return 0, fmt.Errorf(msg("needs_positive"), t.val, "step")
+	}
+	if r.to < r.from {
+		return 0, errors.New(msg("range_step"))
+	}
+	n := r.steps()
+	if n > maxRangeSteps {
+		return 0, fmt.Errorf(msg("range_too_long"), n, maxRangeSteps)
+	}
+	f, restore, err := integrand(t, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	xs, ys := []string{"x"}, []string{"f(x)"}
+	for i := range n {
+		x := r.from + float64(i)*r.step
+		y, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		xs = append(xs, fmt.Sprintf("%.12g", x))
+		ys = append(ys, fmt.Sprintf("%.12g", y))
+	}
+	wx, wy := 0, 0
+	for i := range xs {
+		wx, wy = max(wx, len(xs[i])), max(wy, len(ys[i]))
+	}
+	for i := range xs {
+		ctx.note("%*s  %*s", wx, xs[i], wy, ys[i])
+	}
+	return float64(n), nil
+}
+
+// printSum mostra a soma de "expr for x from a to b [step s]" e guarda-a em
+// ans, como sigma mas com passo qualquer.
+func (s *Session) printSum(spec string) error {
+	r, err := parseRange(spec, s.ctx)
+	if err != nil {
+		return err
+	}
+	sum := 0.0
+	if err := evalRange(r, s.ctx, func(_, y float64) { sum += y }); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, "=", s.formatValue(sum))
+	s.setAns(result{val: sum})
+	return nil
+}
+
+// terminalWidth devolve a largura do terminal indicada em COLUMNS, ou 80.
+func terminalWidth() int {
+	var w int
+	if _, err := fmt.Sscan(os.Getenv("COLUMNS"), &w); err == nil && w >= 20 {
+		return w
+	}
+	return 80
+}
+
+// printChart mostra um gráfico de barras horizontal de "expr for x from a to b".
+// Os valores negativos crescem para a esquerda de um eixo central.
+func (s *Session) printChart(spec string) error {
+	r, err := parseRange(spec, s.ctx)
+	if err != nil {
+		return err
+	}
+	var labels, vals []string
+	var ys []float64
+	err = evalRange(r, s.ctx, func(x, y float64) {
+		labels = append(labels, r.variable+"="+s.formatValue(x))
+		vals = append(vals, s.formatValue(y))
+		ys = append(ys, y)
+	})
+	if err != nil {
+		return err
+	}
+	lw, vw := 0, 0
+	peak, negative := 0.0, false
+	for i, y := range ys {
+		lw = max(lw, utf8.RuneCountInString(labels[i]))
+		vw = max(vw, len(vals[i]))
+		if !math.IsInf(y, 0) && !math.IsNaN(y) {
+			peak = math.Max(peak, math.Abs(y))
+			negative = negative || y < 0
+		}
+	}
+	room := max(terminalWidth()-lw-vw-4, 10)
+	half := room
+	if negative {
+		half = (room - 1) / 2
+	}
+	for i, y := range ys {
+		n := 0
+		if peak > 0 && !math.IsInf(y, 0) && !math.IsNaN(y) {
+			n = int(math.Round(math.Abs(y) / peak * float64(half)))
+		}
+		bar := strings.Repeat("█", n)
+		if negative {
+			left, right := strings.Repeat(" ", half), strings.Repeat(" ", half)
+			if y < 0 {
+				left = strings.Repeat(" ", half-n) + bar
+			} else {
+				right = bar + strings.Repeat(" ", half-n)
+			}
+			bar = left + "│" + right
+		} else {
+			bar += strings.Repeat(" ", half-n)
+		}
+		fmt.Fprintf(s.out, "%-*s  %s  %s\n", lw, labels[i], bar, vals[i])
+	}
+	return nil
+}
+
+// printNumberLine mostra uma reta numérica de lo a hi com × em cada ponto.
+// Aceita "lo hi p1,p2,..." ou só "p1,p2,...", caso em que o intervalo se
+// ajusta aos pontos.
+func (s *Session) printNumberLine(arg string) error {
+	fields := strings.Fields(arg)
+	if len(fields) != 1 && len(fields) != 3 {
+		return errors.New(msg("usage_numberline"))
+	}
+	sep := ","
+	if s.ctx.decimalComma {
+		sep = ";"
+	}
+	var points []float64
+	for _, p := range strings.Split(fields[len(fields)-1], sep) {
+		v, err := evalExpr(p, s.ctx)
+		if err != nil {
+			return err
+		}
+		points = append(points, v)
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	if len(fields) == 3 {
+		var err error
+		if lo, err = evalExpr(fields[0], s.ctx); err != nil {
+			return err
+		}
+		if hi, err = evalExpr(fields[1], s.ctx); err != nil {
+			return err
+		}
+	}
+	for _, p := range points {
+		lo, hi = math.Min(lo, p), math.Max(hi, p)
+	}
+	if len(fields) == 1 {
+		lo, hi = math.Floor(lo), math.Ceil(hi)
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	width := min(terminalWidth()-2, 100)
+	pos := func(v float64) int { return int(math.Round((v - lo) / (hi - lo) * float64(width-1))) }
+
+	// marcas em números redondos: 1, 2, 5, 10, 20, 50, ... com no máximo uma
+	// a cada quatro colunas
+	tick := 1.0
+	for (hi-lo)/tick > float64(width)/4 {
+		switch d := tick / math.Pow(10, math.Floor(math.Log10(tick))); d {
+		case 2:
+			tick *= 2.5
+		default:
+			tick *= 2
+		}
+	}
+	marks := []rune(strings.Repeat(" ", width))
+	axis := []rune(strings.Repeat("─", width))
+	labels := []rune(strings.Repeat(" ", width+8))
+	axis[0], axis[width-1] = '├', '┤'
+	next := 0 // primeira coluna livre na linha das legendas
+	for t := math.Ceil(lo/tick) * tick; t <= hi+1e-9; t += tick {
+		c := pos(t)
+		if c > 0 && c < width-1 {
+			axis[c] = '┼'
+		}
+		l := []rune(s.formatValue(t))
+		start := max(c-len(l)/2, 0)
+		if start >= next && start+len(l) <= len(labels) {
+			copy(labels[start:], l)
+			next = start + len(l) + 1
+		}
+	}
+	for _, p := range points {
+		marks[pos(p)] = '×'
+	}
+	fmt.Fprintln(s.out, " "+strings.TrimRight(string(marks), " "))
+	fmt.Fprintln(s.out, " "+string(axis))
+	fmt.Fprintln(s.out, " "+strings.TrimRight(string(labels), " "))
+	return nil
+}
+
+// maxLoopSteps limita o número de termos de sigma e pi_prod, e de pontos de
+// verify_identity.
+const maxLoopSteps = 1000000
+
+// loopFunc descreve uma função como sigma, que avalia a expressão para cada
+// valor inteiro da variável entre a e b e combina os resultados.
+type loopFunc struct {
+	empty   float64 // resultado quando a > b
+	combine func(acc, y float64) float64
+}
+
+var loopFuncs = map[string]loopFunc{
+	"sigma":   {empty: 0, combine: func(acc, y float64) float64 { return acc + y }},
+	"pi_prod": {empty: 1, combine: func(acc, y float64) float64 { return acc * y }}, // Π; pi é a constante
+}
+
+// evalLazy chama uma função cujos primeiros argumentos ficaram por avaliar
+// (FuncDef.lazy), como sigma; args são os restantes, já avaliados.
+func evalLazy(t token, args []float64, ctx *EvalContext) (float64, error) {
+	switch t.val {
+	case "verify_identity":
+		return verifyIdentity(t, args, ctx)
+	case "gradient_descent":
+		return gradientDescent(t, args, ctx)
+	case "fixed_point":
+		return fixedPoint(t, args, ctx)
+	case "tabulate":
+		return tabulate(t, args, ctx)
+	case "integral_approx":
+		return integralApprox(t, args, ctx)
+	case "number_format":
+		return numberFormatCall(t, ctx)
+	case "complexity":
+		return float64(Complexity(t.lazy[0])), nil
+	}
+	return evalLoop(t, args, ctx)
+}
+
+// bindVar liga temporariamente a variável name; a função devolvida repõe o
+// valor anterior (ou apaga a variável, se não existia). Enquanto está ligada,
+// a variável só tem o valor em vars: o de varResults sai e volta no fim.
+func bindVar(ctx *EvalContext, name string) (restore func(), err error) {
+	if _, ok := constants[name]; ok || name == "ans" {
+		return nil, fmt.Errorf(msg("range_var"), name)
+	}
+	old, had := ctx.vars[name]
+	oldResult, hadResult := ctx.varResults[name]
+	delete(ctx.varResults, name)
+	return func() {
+		if had {
+			ctx.vars[name] = old
+		} else {
+			delete(ctx.vars, name)
+		}
+		if hadResult {
+			ctx.varResults[name] = oldResult
+		}
+	}, nil
+}
+
+// evalLoop avalia a expressão de sigma(expr, x, a, b) para x = a, a+1, ..., b,
+// com a variável temporariamente ligada a x. Como usa evalRPN, as chamadas
+// podem encaixar-se: sigma(sigma(i*j, j, 1, i), i, 1, 3).
+func evalLoop(t token, args []float64, ctx *EvalContext) (float64, error) {
+	lf, body := loopFuncs[t.val], t.lazy[0]
+	if len(t.lazy[1]) != 1 || t.lazy[1][0].typ != tIdent {
+		return 0, errAt(t.offset, fmt.Errorf(msg("loop_usage"), t.val))
+	}
+	variable := t.lazy[1][0].val
+	from, err := asInt(t.val, args[0])
+	if err != nil {
+		return 0, err
+	}
+	to, err := asInt(t.val, args[1])
+	if err != nil {
+		return 0, err
+	}
+	if from > to {
+		return lf.empty, nil
+	}
+	if to-from >= maxLoopSteps {
+		return 0, fmt.Errorf(msg("range_too_long"), to-from+1, maxLoopSteps)
+	}
+	restore, err := bindVar(ctx, variable)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	acc := lf.empty
+	for x := from; x <= to; x++ {
+		ctx.vars[variable] = float64(x)
+		y, err := evalRPN(body, ctx)
+		if err != nil {
+			return 0, fmt.Errorf("%s = %d: %w", variable, x, err)
+		}
+		acc = lf.combine(acc, y)
+		ctx.watch.update(ctx, false)
+	}
+	ctx.watch.finish(ctx)
+	return acc, nil
+}
+
+// verifyIdentity compara as duas expressões de verify_identity(f, g, n) em n
+// pontos aleatórios de x em [-10, 10]: devolve 1 se concordarem em todos (a
+// menos de 1e-10, relativo para valores grandes) e 0 caso contrário. A semente
+// é fixa, para que o resultado seja reprodutível. Pontos em que nenhuma das
+// expressões está definida (ex.: tan(x) nos polos) são ignorados.
+func verifyIdentity(t token, args []float64, ctx *EvalContext) (float64, error) {
+	n, err := asInt(t.val, args[0])
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > maxLoopSteps {
+		return 0, fmt.Errorf(msg("verify_samples"), maxLoopSteps)
+	}
+	restore, err := bindVar(ctx, "x")
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	eval := func(rpn []token) float64 {
+		v, err := evalRPN(rpn, ctx)
+		if err != nil {
+			return math.NaN()
+		}
+		return v
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := int64(0); i < n; i++ {
+		x := rng.Float64()*20 - 10
+		ctx.vars["x"] = x
+		a, b := eval(t.lazy[0]), eval(t.lazy[1])
+		if math.IsNaN(a) && math.IsNaN(b) {
+			continue
+		}
+		if !(math.Abs(a-b) <= 1e-10*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))) {
+			if ctx.debug {
+				ctx.note("x = %.17g: %.17g != %.17g", x, a, b)
+			}
+			return 0, nil
+		}
+	}
+	return 1, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runREPL corre o REPL sobre in e devolve o que escreveu na saída e nos erros.
+func runREPL(t *testing.T, in io.Reader) (out, errOut string) {
+	t.Helper()
+	t.Setenv("CALC_LOCALE", "C")
+	var o, e bytes.Buffer
+	newSession(in, &o, &e).repl()
+	return o.String(), e.String()
+}
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     []string // pedaços que têm de aparecer na saída
+		unwanted []string // pedaços que não podem aparecer na saída
+		errs     []string // pedaços que têm de aparecer nos erros
+	}{
+		{
+			name:  "aritmética",
+			input: "2+2*3\n(1+2)^3/9\n",
+			want:  []string{"= 8\n", "= 3\n"},
+		},
+		{
+			name:  "funções",
+			input: "sqrt(16)\nmax(3, 9)\nsin(0)\n",
+			want:  []string{"= 4\n", "= 9\n", "= 0\n"},
+		},
+		{
+			name:  "atribuição e ans",
+			input: "x = 5\nx*2\nans+1\n",
+			want:  []string{"x = 5\n", "= 10\n", "= 11\n"},
+		},
+		{
+			name:     "erros",
+			input:    "1/0\nfoo(1)\n2+\n3+3\n",
+			want:     []string{"= 6\n"},
+			unwanted: []string{msg("error")},
+			errs:     []string{msg("error") + " " + msg("division_by_zero"), "foo", msg("error")},
+		},
+		{
+			// ; separa as expressões também com vírgula decimal; um where
+			// fica com o resto da linha, onde ; separa os seus argumentos
+			name:  "vírgula decimal",
+			input: ":csv_mode on\n2,5+2,5; 3+3\n:def k := a*b\nx = 2; k where a=1,5; b=x\n",
+			want:  []string{"= 5\n", "= 6\n", "x = 2\n", "= 3\n"},
+		},
+		{
+			name:  "ajuda em várias linhas",
+			input: ":help\n",
+			want:  []string{"2+2*3", ":quit", ":help", ":roman XIV"},
+		},
+		{
+			name:     "quit",
+			input:    "1+1\n:quit\n2+2\n",
+			want:     []string{"= 2\n"},
+			unwanted: []string{"= 4\n"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, errOut := runREPL(t, strings.NewReader(tc.input))
+			if !strings.HasPrefix(out, msg("banner")+"\n") {
+				t.Errorf("a saída não começa pelo banner: %q", out)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("falta %q na saída:\n%s", w, out)
+				}
+			}
+			for _, u := range tc.unwanted {
+				if strings.Contains(out, u) {
+					t.Errorf("%q não devia estar na saída:\n%s", u, out)
+				}
+			}
+			for _, e := range tc.errs {
+				if !strings.Contains(errOut, e) {
+					t.Errorf("falta %q nos erros:\n%s", e, errOut)
+				}
+			}
+			if tc.errs == nil && errOut != "" {
+				t.Errorf("erros inesperados: %s", errOut)
+			}
+		})
+	}
+}
+
+// TestREPLHelpLines verifica que :help escreve o texto todo, linha a linha.
+func TestREPLHelpLines(t *testing.T) {
+	out, _ := runREPL(t, strings.NewReader(":help\n"))
+	want := strings.Count(msg("help"), "\n") + 1
+	if got := strings.Count(out, "\n"); got < want {
+		t.Errorf(":help escreveu %d linhas, esperava pelo menos %d", got, want)
+	}
+}
+
+// TestREPLPipe corre o REPL sobre um pipe, como em "echo 2+2 | calc": não é
+// interativo, por isso um erro numa linha com ; pára as expressões seguintes
+// e não há ^ a assinalar a posição.
+func TestREPLPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "7*6\n1/0; 2+3\n10-1\n")
+		w.Close()
+	}()
+	if isInteractive(r) {
+		t.Fatal("um pipe não é interativo")
+	}
+	out, errOut := runREPL(t, r)
+	for _, want := range []string{"= 42\n", "= 9\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("falta %q na saída:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "= 5\n") {
+		t.Errorf("o erro devia parar a linha com ;:\n%s", out)
+	}
+	if !strings.Contains(errOut, msg("division_by_zero")) || strings.Contains(errOut, "^") {
+		t.Errorf("erros: %q", errOut)
+	}
+}
+
+// Com CALC_LOG, cada expressão com sucesso fica no registo com os valores que
+// calculou, venha de onde vier; os erros e os comandos sem valor não ficam.
+func TestREPLLog(t *testing.T) {
+	t.Setenv("CALC_LOCALE", "C")
+	path := filepath.Join(t.TempDir(), "calc.log")
+	t.Setenv("CALC_LOG", path)
+	input := strings.Join([]string{
+		"2+2; 1/0",
+		"x = 5",
+		"x*2",
+		"!x=1",
+		":rpn 3 4 +",
+		"eval x^2 for x from 1 to 3",
+		"2*3",
+		":repeat 2",
+		":def k := a*b",
+		"k where a=2, b=10",
+		":precision 4",
+	}, "\n")
+	var o, e bytes.Buffer
+	s := newSession(strings.NewReader(input), &o, &e)
+	f, err := openLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.log = f
+	s.repl()
+	f.Close()
+
+	type entry struct {
+		expr   string
+		result float64
+	}
+	want := []entry{
+		{"2+2", 4},
+		{"x = 5", 5},
+		{"x*2", 10},
+		{"!x=1", 2},
+		{":rpn 3 4 +", 7},
+		{"eval x^2 for x from 1 to 3", 1},
+		{"eval x^2 for x from 1 to 3", 4},
+		{"eval x^2 for x from 1 to 3", 9},
+		{"2*3", 6},
+		{":repeat 2", 6},
+		{":repeat 2", 6},
+		{"k where a=2, b=10", 20},
+	}
+	data, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	var got []entry
+	sc := bufio.NewScanner(data)
+	for sc.Scan() {
+		var le logEntry
+		if err := json.Unmarshal(sc.Bytes(), &le); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		if le.TS == "" {
+			t.Errorf("%q: falta ts", sc.Text())
+		}
+		v, _ := le.Result.(float64)
+		got = append(got, entry{le.Expr, v})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("registo com %d linhas, want %d:\n%v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("linha %d = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// selfTests são os pares expressão/resultado verificados por :test.
+var selfTests = []struct {
+	expr     string
+	expected float64
+}{
+	{"2+2*3", 8},
+	{"(1+2)^3/9", 3},
+	// precedência: | < + - < * / // < unários < ^
+	{"2*3+4*5", 26},
+	{"2+3*4-5", 9},
+	{"8-6/2", 5},
+	{"1+2|4", 7},
+	{"1|2+4", 7},
+	{"2*3^2", 18},
+	{"2^2*3", 12},
+	{"7//2*2", 6},
+	// associatividade: ^ à direita, os restantes à esquerda
+	{"2^3^2", 512},
+	{"(2^3)^2", 64},
+	{"2^-3^2", 1.0 / 512},
+	{"10-4-3", 3},
+	{"100/10/5", 2},
+	{"2/4*2", 1},
+	{"17//5//2", 1},
+	// unários: -x^y é -(x^y), e um unário pode seguir-se a outro operador
+	{"-2^2", -4},
+	{"(-2)^2", 4},
+	{"--1", 1},
+	{"-+-1", 1},
+	{"2^-1", 0.5},
+	{"2*-3", -6},
+	{"-2*3", -6},
+	{"-3+5", 2},
+	{"max(-1, -2)", -1},
+	{"-sqrt(4)", -2},
+	// parênteses e chamadas encadeadas
+	{"((((1))))", 1},
+	{"(1+2)*(3+4)", 21},
+	{"sin(cos(0)-1)", 0},
+	{"sqrt(sqrt(16))", 2},
+	{"max(1, min(5, 3)) ^ 2", 9},
+	{"7//2", 3},
+	{"-7//2", -4},
+	{"5|2", 7},
+	{"2×3÷4", 1.5},
+	{"1_000 + 0.000_5", 1000.0005},
+	{"3²", 9},
+	{"|-3.5|", 3.5},
+	{"sin(0)", 0},
+	{"cos(0)", 1},
+	{"sin(pi/2)", 1},
+	{"tan(pi/4)", 1},
+	{"sqrt(16)", 4},
+	{"log(100)", 2},
+	{"ln(e)", 1},
+	{"abs(-2.5)", 2.5},
+	{"floor(2.7)", 2},
+	{"ceil(2.1)", 3},
+	{"round(2.5)", 3},
+	{"max(3, 9)", 9},
+	{"min(4, -2)", -2},
+	{"divmod_q(-7, 2)", -4},
+	{"divmod_r(-7, 3)", 2},
+	{"copysign(3, -1)", -3},
+	{"remainder(5, 3)", -1},
+	{"remainder(-3.5, 1)", 0.5},
+	{"fmod(-7, 3)", -1},
+	{"dim(5, 3)", 2},
+	{"cbrt(-8)", -2},
+	{"cbrt(-27)", -3},
+	{"(-27)^(1/3)", math.NaN()},
+	{"isqrt(17)", 4},
+	{"factorial(5)", 120},
+	{"factorial(0)", 1},
+	{"poly(3, 1, 2, 1)", 16},
+	{"poly(2, 1, 0, 1)", 5},
+	{"polyderiv(2, 0, 0, 1)", 4},
+	{"cf_convergent(pi, 4)", 355.0 / 113},
+	{"floorm(2.7, 0.5)", 2.5},
+	{"ceilm(-2.3, 0.25)", -2.25},
+	{"floorm(-2.7, 0.5)", -3},
+	{"floorm(-0.1, 1)", -1},
+	{"ceilm(2.3, 0.25)", 2.5},
+	{"ceilm(-2.7, 0.5)", -2.5},
+	{"sigma(k^2, k, 1, 10)", 385},
+	{"pi_prod(k, k, 1, 5)", 120},
+	{"sigma(k, k, 5, 1)", 0},
+	{"det2(1, 2, 3, 4)", -2},
+	{"det3(2, 0, 0, 0, 3, 0, 0, 0, 4)", 24},
+	{"cross2(1, 0, 0, 1)", 1},
+	{"dot3(1, 2, 3, 4, 5, 6)", 32},
+	{"binom_pmf(3, 10, 0.5)", 0.1171875},
+	{"normal_cdf(0, 0, 1)", 0.5},
+	{"qnorm(0.5)", 0},
+	{"t_ppf(0.5, 5)", 0},
+	{"t_ppf(1-0.975, 20) + t_ppf(0.975, 20)", 0},
+	{"t_ppf(0.1, 3) + t_ppf(1-0.1, 3)", 0},
+	{"abs(t_ppf(0.975, 20) - 2.086) < 1e-3", 1},
+	{"ma(1, 2, 3, 4, 5, 3)", 4},
+	{"cumsum(1, 2, 3, 4, 5)", 15},
+	{"cumprod(1, 2, 3, 4, 5)", 120},
+	{"bits(4080, 11, 4)", 255},
+	{"bits(0xFF0, 11, 4)", 255},
+	{"0xdead_beef", 3735928559},
+	{"mobius(30)", -1},
+	{"bigomega(12)", 3},
+	{"digitalroot(0)", 0},
+	{"digitalroot(18)", 9},
+	{"digitalroot(9)", 9},
+	{"catalan(5)", 42},
+	{"bell(5)", 52},
+	{"lucas(10)", 123},
+	{"fib(10)", 55},
+	{"verify_identity(sin(x)^2 + cos(x)^2, 1, 100)", 1},
+	{"gradient_descent((x-3)^2, 0, 0.25, 50)", 3},
+	{"chebT(3, 0.5)", -1},
+	{"chebT(2.5, 0.5)", math.Cos(2.5 * math.Pi / 3)},
+	{"chebU(3, 0.5)", -1},
+	{"chebU(2, 2)", 15},
+	{"legendre(2, 0.5)", -0.125},
+	{"legendre(3, 1)", 1},
+	{"legendre_roots(2)", 1 / math.Sqrt(3)},
+	{"lambertW(0)", 0},
+	{"lambertW(e)", 1},
+	{"lambertW(-1/e)", -1},
+	{"lambertW(2*e^2)", 2},
+	{"zeta(2)", math.Pi * math.Pi / 6},
+	{"zeta(4)", math.Pow(math.Pi, 4) / 90},
+	{"zeta(0)", -0.5},
+	{"zeta(-1)", -1.0 / 12},
+	{"poch(3, 4)", 360},
+	{"fallfact(10, 3)", 720},
+	{"poch(1, 5)", 120},
+	{"poch(0.5, 2.5)", math.Gamma(3) / math.Gamma(0.5)},
+	{"poch(5, -2)", 1.0 / 12},
+	{"primorial(10)", 210},
+	{"primorial(1)", 1},
+	{"prime_pi(100)", 25},
+	{"prime_pi(2)", 1},
+	{"solve_quadratic(1, -5, 6)", 3},
+	{"solve_quadratic(1, 1e8, 1)", -1e-8},
+	{"solve_quadratic(2, 0, -8)", 2},
+	{"integral_approx(x^2, 0, 3)", 9},
+	{"integral_approx(sin, 0, pi, 10, gauss5)", 2},
+	{"integral_approx(x, 0, 1, 4, trapezoid)", 0.5},
+	{"integral_approx(2*x + 1, 0, 2, 3, midpoint)", 6},
+	{"tabulate(x^2, 1, 3, 0.5)", 5},
+	{"tabulate(sin, 0, pi, pi/6)", 7},
+	{"to_words(1000000)", 0},
+	{"goldenratio_approx(10)", 89.0 / 55},
+	{"abs(goldenratio_approx(25) - (1+sqrt(5))/2) < 1e-10", 1},
+	// comparações e o condicional, que ligam menos do que os outros operadores
+	{"1 + 1 == 2", 1},
+	{"2 < 1", 0},
+	{"3 >= 3", 1},
+	{"1 != 1", 0},
+	{"2 > 1 ? 10 : 20", 10},
+	{"0 ? 1 : 0 ? 2 : 3", 3},
+	{"1 ? 0 ? 4 : 5 : 6", 5},
+	{"2 > 1 ? -1 : 1", -1},
+	{"max(1 < 2 ? 3 : 4, 0)", 3},
+	{"15% of 200", 30},
+	{"100 * 15%", 15},
+	{"(10 + 5)% of 200 + 100", 45},
+	{"-50%", -0.5},
+}
+
+// exactSelfTests são verificados no modo :exact, onde n/m entre inteiros é
+// uma fração exata (big.Rat) e não uma divisão em float64.
+var exactSelfTests = []struct {
+	expr     string
+	expected string
+}{
+	{"1/3 + 1/6", "1/2"},
+	{"1/3", "1/3"},
+	{"-1/3 * 3", "-1"},
+	{"(2/3)^2", "4/9"},
+	{"2^-3", "1/8"},
+	{"0.1 + 0.2", "3/10"},
+	{"floor(7/2)", "3"},
+	{"7//2", "3"},
+	{"factorial(25)", "15511210043330985984000000"},
+	{"primorial(100)", "2305567963945518424753102147331756070"},
+	{"15% of 7", "21/20"},
+}
+
+// romanSelfTests são verificados nos dois sentidos, roman(n) e :roman, e
+// cobrem todas as formas subtrativas.
+var romanSelfTests = []struct {
+	n       int
+	numeral string
+}{
+	{4, "IV"}, {9, "IX"}, {14, "XIV"}, {40, "XL"}, {90, "XC"}, {400, "CD"}, {900, "CM"},
+	{1994, "MCMXCIV"}, {2024, "MMXXIV"}, {3999, "MMMCMXCIX"},
+}
+
+// noteSelfTests verificam o que a função mostra, além do valor: as notas
+// de ctx.note, uma por linha.
+var noteSelfTests = []struct {
+	expr     string
+	expected string
+}{
+	{"prime_factors(1)", ""},
+	{"prime_factors(2)", "2"},
+	{"prime_factors(12)", "2^2 × 3"},
+	{"prime_factors(360)", "2^3 × 3^2 × 5"},
+	{"to_words(42)", "forty-two"},
+	{"to_words(1000000)", "one million"},
+	{"roman(1994)", "MCMXCIV"},
+}
+
+// runSelfTests avalia cada expressão de selfTests num contexto novo e mostra
+// PASS/FAIL; devolve o número de falhas.
+func runSelfTests(out io.Writer) int {
+	failed := 0
+	for _, tc := range selfTests {
+		got, err := evalExpr(tc.expr, &EvalContext{vars: map[string]float64{}})
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", tc.expr, err)
+		case math.IsNaN(tc.expected) != math.IsNaN(got),
+			math.Abs(got-tc.expected) > 1e-12*math.Max(1, math.Abs(tc.expected)):
+			failed++
+			fmt.Fprintf(out, "FAIL %s = %.15g, "+msg("test_expected")+"\n", tc.expr, got, tc.expected)
+		default:
+			fmt.Fprintf(out, "PASS %s = %.15g\n", tc.expr, got)
+		}
+	}
+	for _, tc := range exactSelfTests {
+		ctx := &EvalContext{vars: map[string]float64{}, exact: true}
+		rpn, err := compile(tc.expr, ctx)
+		var got *big.Rat
+		if err == nil {
+			got, err = evalExact(rpn, ctx)
+		}
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL :exact %s: %v\n", tc.expr, err)
+		case got.RatString() != tc.expected:
+			failed++
+			fmt.Fprintf(out, "FAIL :exact %s = %s, "+msg("test_expected_exact")+"\n", tc.expr, got.RatString(), tc.expected)
+		default:
+			fmt.Fprintf(out, "PASS :exact %s = %s\n", tc.expr, got.RatString())
+		}
+	}
+	for _, tc := range romanSelfTests {
+		got, err := fromRoman(tc.numeral)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL :roman %s: %v\n", tc.numeral, err)
+		case got != tc.n || toRoman(tc.n) != tc.numeral:
+			failed++
+			fmt.Fprintf(out, "FAIL roman(%d) = %s, :roman %s = %d\n", tc.n, toRoman(tc.n), tc.numeral, got)
+		default:
+			fmt.Fprintf(out, "PASS roman(%d) = %s\n", tc.n, tc.numeral)
+		}
+	}
+	for _, tc := range noteSelfTests {
+		ctx := &EvalContext{vars: map[string]float64{}}
+		_, err := evalExpr(tc.expr, ctx)
+		got := strings.Join(ctx.notes, "\n")
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", tc.expr, err)
+		case got != tc.expected:
+			failed++
+			fmt.Fprintf(out, "FAIL %s → %q, "+msg("test_expected_exact")+"\n", tc.expr, got, fmt.Sprintf("%q", tc.expected))
+		default:
+			fmt.Fprintf(out, "PASS %s → %q\n", tc.expr, got)
+		}
+	}
+	total := len(selfTests) + len(exactSelfTests) + len(romanSelfTests) + len(noteSelfTests)
+	fmt.Fprintf(out, msg("test_summary")+"\n", total-failed, total, failed)
+	return failed
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSelfTests corre as tabelas de :test em go test, para que uma regressão
+// nelas não passe despercebida.
+func TestSelfTests(t *testing.T) {
+	var out strings.Builder
+	if failed := runSelfTests(&out); failed != 0 {
+		for line := range strings.Lines(out.String()) {
+			if strings.HasPrefix(line, "FAIL") {
+				t.Error(strings.TrimSpace(line))
+			}
+		}
+		t.Fatalf("%d falhas em :test", failed)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limites da API: o endpoint é aberto, por isso nenhum pedido pode ocupar o
+// servidor por muito tempo.
+const (
+	maxServeBody  = 64 << 10 // bytes do corpo de um pedido
+	maxServeCost  = 1000     // Complexity máxima da expressão, como o :maxcost por omissão
+	maxServeEvals = 1000000  // chamadas de evalRPN por pedido: cada passo de sigma conta
+)
+
+// evalRequest é o corpo aceite por POST /eval. context liga variáveis só para
+// esse pedido: o servidor não guarda estado (nem ans) entre pedidos.
+type evalRequest struct {
+	Expr    string             `json:"expr"`
+	Context map[string]float64 `json:"context"`
+}
+
+type evalResponse struct {
+	Result *float64 `json:"result"`
+	Error  *string  `json:"error"`
+	Notes  []string `json:"notes,omitempty"`
+}
+
+// handleEval avalia a expressão recebida em JSON e responde com o resultado
+// ou com o erro.
+func handleEval(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var req evalRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxServeBody)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeEvalResponse(w, status, evalResponse{}, err)
+		return
+	}
+	res, _, ctx, err := evalStateless(req.Expr, req.Context)
+	resp := evalResponse{Notes: ctx.notes}
+	if err != nil {
+		writeEvalResponse(w, http.StatusUnprocessableEntity, resp, err)
+		return
+	}
+	resp.Result = &res
+	writeEvalResponse(w, http.StatusOK, resp, nil)
+}
+
+func writeEvalResponse(w http.ResponseWriter, status int, resp evalResponse, err error) {
+	if err != nil {
+		e := err.Error()
+		resp.Error = &e
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// evalStateless avalia expr num contexto novo, só com as variáveis dadas, e
+// dentro dos limites da API: recusa expressões acima de maxServeCost e pára
+// ao fim de maxServeEvals passos. Um resultado não finito é um erro. Devolve
+// também a forma pós-fixa, quando a expressão compilou (o gRPC mostra-a).
+func evalStateless(expr string, vars map[string]float64) (float64, []token, *EvalContext, error) {
+	ctx := &EvalContext{vars: map[string]float64{}, maxEvals: maxServeEvals}
+	for k, v := range vars {
+		ctx.vars[strings.ToLower(k)] = v
+	}
+	rpn, err := compile(expr, ctx)
+	if err != nil {
+		return 0, nil, ctx, err
+	}
+	if cost := Complexity(rpn); cost > maxServeCost {
+		return 0, rpn, ctx, fmt.Errorf(msg("too_costly"), cost, maxServeCost)
+	}
+	res, err := evalRPN(rpn, ctx)
+	if err == nil && (math.IsNaN(res) || math.IsInf(res, 0)) {
+		err = errors.New(msg("non_finite"))
+	}
+	return res, rpn, ctx, err
+}
+
+// serve inicia a API HTTP no endereço dado, ex.: ":8080".
+func serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/eval", handleEval)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
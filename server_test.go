@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postEval faz um POST /eval com o corpo dado e devolve o estado e a resposta.
+func postEval(t *testing.T, url, body string) (int, evalResponse) {
+	t.Helper()
+	r, err := http.Post(url+"/eval", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	var resp evalResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return r.StatusCode, resp
+}
+
+func TestHandleEval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(handleEval))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		result float64
+		err    string // pedaço da mensagem de erro; "" quando há resultado
+	}{
+		{"sucesso", `{"expr": "sin(pi/2)"}`, http.StatusOK, 1, ""},
+		{"context", `{"expr": "x*y + 1", "context": {"X": 20, "y": 2}}`, http.StatusOK, 41, ""},
+		{"erro", `{"expr": "1/0"}`, http.StatusUnprocessableEntity, 0, msg("division_by_zero")},
+		// depois do pedido com context: nem x nem ans passam para o seguinte
+		{"sem estado: x", `{"expr": "x"}`, http.StatusUnprocessableEntity, 0, "x"},
+		{"sem estado: ans", `{"expr": "ans + 1"}`, http.StatusOK, 1, ""},
+		{"não finito", `{"expr": "1e308*10"}`, http.StatusUnprocessableEntity, 0, msg("non_finite")},
+		{"JSON inválido", `{"expr": `, http.StatusBadRequest, 0, "EOF"},
+		{"custo", `{"expr": "1` + strings.Repeat("+1", maxServeCost+1) + `"}`, http.StatusUnprocessableEntity, 0, "1001"},
+		{"passos", `{"expr": "sigma(sigma(k, k, 1, 1e6), j, 1, 1e6)"}`, http.StatusUnprocessableEntity, 0, "1000000"},
+		{"corpo grande", `{"expr": "` + strings.Repeat(" ", maxServeBody) + `1"}`, http.StatusRequestEntityTooLarge, 0, "too large"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			status, resp := postEval(t, srv.URL, tc.body)
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("o pedido demorou %v", time.Since(start))
+			}
+			if status != tc.status {
+				t.Errorf("estado %d, want %d", status, tc.status)
+			}
+			if tc.err == "" {
+				if resp.Error != nil || resp.Result == nil || *resp.Result != tc.result {
+					t.Errorf("resposta %+v, want result %v", resp, tc.result)
+				}
+				return
+			}
+			if resp.Result != nil || resp.Error == nil || !strings.Contains(*resp.Error, tc.err) {
+				t.Errorf("resposta %+v, want erro com %q", resp, tc.err)
+			}
+		})
+	}
+}
+
+// O preflight de CORS responde sem corpo, com os cabeçalhos para o browser;
+// outros métodos além de POST são recusados.
+func TestHandleEvalCORS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(handleEval))
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodOptions, srv.URL+"/eval", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusNoContent {
+		t.Errorf("estado %d, want %d", r.StatusCode, http.StatusNoContent)
+	}
+	for h, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type",
+	} {
+		if got := r.Header.Get(h); got != want {
+			t.Errorf("%s = %q, want %q", h, got, want)
+		}
+	}
+	r, err = http.Get(srv.URL + "/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET: estado %d, want %d", r.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
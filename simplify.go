@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// :simplify — dobragem de constantes: as subexpressões sem variáveis são
+// avaliadas e substituídas pelo valor, ex.: x*(3+4) → x * 7. Não há ainda
+// simplificação simbólica (2*x + 2*y continua igual).
+
+// foldConstants devolve uma cópia da forma pós-fixa com as subexpressões
+// constantes já calculadas. Só os números contam como constantes (pi e e
+// ficam com o nome), e uma subexpressão que dê erro ou um valor não finito
+// (1/0, sqrt(-1)) fica como estava, para o erro aparecer ao avaliar.
+func foldConstants(rpn []token, ctx *EvalContext) []token {
+	type part struct {
+		rpn   []token
+		konst bool
+	}
+	scratch := &EvalContext{vars: map[string]float64{}, intMode: ctx.intMode, angleUnit: ctx.angleUnit}
+	var st []part
+	for _, t := range rpn {
+		var n int
+		switch t.typ {
+		case tNumber, tIdent, tString:
+			st = append(st, part{[]token{t}, t.typ == tNumber})
+			continue
+		case tOp:
+			n = 2
+			if ops[t.val].unary {
+				n = 1
+			}
+		case tFunc:
+			n, _ = argCount(t)
+			if t.lazy != nil {
+				lazy := make([][]token, len(t.lazy))
+				for i, l := range t.lazy {
+					lazy[i] = foldConstants(l, ctx)
+				}
+				t.lazy = lazy
+			}
+		}
+		if len(st) < n {
+			return rpn // forma inválida: o erro aparece ao avaliar
+		}
+		p := part{konst: t.lazy == nil}
+		for _, a := range st[len(st)-n:] {
+			p.rpn = append(p.rpn, a.rpn...)
+			p.konst = p.konst && a.konst
+		}
+		p.rpn = append(p.rpn, t)
+		st = st[:len(st)-n]
+		if p.konst {
+			v, err := evalRPN(p.rpn, scratch)
+			if err == nil && !math.IsInf(v, 0) && !math.IsNaN(v) {
+				p.rpn = numberTokens(v, t.offset)
+			} else {
+				p.konst = false
+			}
+		}
+		st = append(st, p)
+	}
+	if len(st) != 1 {
+		return rpn
+	}
+	return st[0].rpn
+}
+
+// numberTokens escreve v como tokens: um número, seguido de u- se negativo.
+func numberTokens(v float64, offset int) []token {
+	num := token{typ: tNumber, val: strconv.FormatFloat(math.Abs(v), 'g', 15, 64), offset: offset}
+	if v < 0 {
+		return []token{num, {typ: tOp, val: "u-", offset: offset}}
+	}
+	return []token{num}
+}
+
+// printSimplified mostra a expressão com as constantes dobradas e a
+// complexidade antes e depois.
+func (s *Session) printSimplified(expr string) error {
+	rpn, err := compile(expr, s.ctx)
+	if err != nil {
+		return err
+	}
+	folded := foldConstants(rpn, s.ctx)
+	inf, err := RPNToInfix(folded)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, inf)
+	fmt.Fprintf(s.out, "  "+msg("simplify_cost")+"\n", Complexity(rpn), Complexity(folded))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// :sizeof — anatomia do float64 do último resultado: os 8 bytes em sinal,
+// expoente e mantissa, a classe do valor, os dígitos significativos e o ULP.
+
+// floatClass devolve a chave da mensagem com a classe IEEE 754 de v.
+func floatClass(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "class_nan"
+	case math.IsInf(v, 0):
+		return "class_inf"
+	case v == 0:
+		return "class_zero"
+	case math.Abs(v) < 0x1p-1022:
+		return "class_subnormal"
+	}
+	return "class_normal"
+}
+
+// printSizeof mostra a representação binária de ans.
+func (s *Session) printSizeof() {
+	v := s.ctx.lastAns
+	b := strconv.FormatUint(math.Float64bits(v), 2)
+	b = strings.Repeat("0", 64-len(b)) + b
+	fmt.Fprintf(s.out, "  ans = %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	fmt.Fprintf(s.out, "  "+msg("sizeof_bytes")+"\n", 8)
+	fmt.Fprintf(s.out, "  "+msg("sizeof_bits")+"\n", b[:1], b[1:12], b[12:])
+	fmt.Fprintf(s.out, "  "+msg("sizeof_class")+"\n", msg(floatClass(v)))
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	// v = m·2^e com m em [1, 2) nos normais; nos subnormais o expoente fica
+	// em -1022 e m em (0, 1)
+	m, e := math.Frexp(v)
+	m, e = 2*m, e-1
+	if e < -1022 {
+		m, e = v*0x1p1022, -1022
+	}
+	if v != 0 {
+		fmt.Fprintf(s.out, "  %s = %s × 2^%d\n", strconv.FormatFloat(v, 'g', -1, 64), strconv.FormatFloat(m, 'g', -1, 64), e)
+	}
+	mant, _, _ := strings.Cut(strconv.FormatFloat(math.Abs(v), 'e', -1, 64), "e")
+	fmt.Fprintf(s.out, "  "+msg("sizeof_digits")+"\n", len(strings.Replace(mant, ".", "", 1)))
+	ulp := math.Nextafter(math.Abs(v), math.Inf(1)) - math.Abs(v)
+	fmt.Fprintf(s.out, "  ulp: %g\n", ulp)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Métodos iterativos sobre uma expressão em x: gradient_descent(f, x0, lr,
+// passos) minimiza f, fixed_point(g, x0, tol) itera x = g(x). Tal como em
+// verify_identity, a expressão é um argumento por avaliar (FuncDef.lazy).
+
+const (
+	maxSolverIters = 1000  // iterações de fixed_point antes de desistir
+	divergeLimit   = 1e150 // |x| acima disto conta como divergência
+)
+
+// solverArgs liga x e devolve uma função que avalia a expressão num ponto.
+func solverArgs(t token, ctx *EvalContext) (f func(x float64) (float64, error), restore func(), err error) {
+	restore, err = bindVar(ctx, "x")
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(x float64) (float64, error) {
+		ctx.vars["x"] = x
+		y, err := evalRPN(t.lazy[0], ctx)
+		if err != nil {
+			return 0, fmt.Errorf("x = %g: %w", x, err)
+		}
+		return y, nil
+	}, restore, nil
+}
+
+// diverged diz se a iteração fugiu para infinito (ou deixou de ser um número).
+func diverged(x float64) bool { return !(math.Abs(x) <= divergeLimit) }
+
+// gradientDescent faz passos x -= lr·f'(x), com a derivada por diferenças
+// centrais (f(x+h) - f(x-h))/2h.
+func gradientDescent(t token, args []float64, ctx *EvalContext) (float64, error) {
+	x, lr := args[0], args[1]
+	steps, err := asInt(t.val, args[2])
+	if err != nil {
+		return 0, err
+	}
+	if steps < 0 || steps > maxLoopSteps {
+		return 0, fmt.Errorf(msg("range_too_long"), steps, maxLoopSteps)
+	}
+	f, restore, err := solverArgs(t, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	for i := int64(1); i <= steps; i++ {
+		h := 1e-6 * math.Max(1, math.Abs(x))
+		fp, err := f(x + h)
+		if err != nil {
+			return 0, err
+		}
+		fm, err := f(x - h)
+		if err != nil {
+			return 0, err
+		}
+		x -= lr * (fp - fm) / (2 * h)
+		if diverged(x) {
+			return 0, fmt.Errorf(msg("solver_diverged"), t.val, i)
+		}
+	}
+	return x, nil
+}
+
+// fixedPoint itera x = g(x) até |g(x) - x| < tol.
+func fixedPoint(t token, args []float64, ctx *EvalContext) (float64, error) {
+	x, tol := args[0], args[1]
+	if !(tol > 0) {
+		return 0, fmt.Errorf(msg("needs_positive"), t.val, "tol")
+	}
+	g, restore, err := solverArgs(t, ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	for i := 1; i <= maxSolverIters; i++ {
+		next, err := g(x)
+		if err != nil {
+			return 0, err
+		}
+		if diverged(next) {
+			return 0, fmt.Errorf(msg("solver_diverged"), t.val, i)
+		}
+		if math.Abs(next-x) < tol {
+			if ctx.debug {
+				ctx.note(msg("solver_iters"), i)
+			}
+			return next, nil
+		}
+		x = next
+	}
+	return 0, fmt.Errorf(msg("solver_no_convergence"), t.val, maxSolverIters)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Funções de distribuição do qui-quadrado e do t de Student, e os respetivos
+// valores críticos (chi2_ppf, t_ppf), obtidos por bisseção sobre a CDF.
+// Os algoritmos das funções gama e beta incompletas são os clássicos: série
+// ou fração contínua (método de Lentz), conforme a zona de convergência.
+
+const (
+	specialEps   = 1e-15 // precisão relativa das séries e frações contínuas
+	specialIters = 1000
+	ppfTol       = 1e-10 // largura final do intervalo da bisseção
+	tiny         = 1e-300
+)
+
+// gammaP é a função gama incompleta inferior regularizada P(a, x).
+func gammaP(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lg, _ := math.Lgamma(a)
+	front := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		sum, del := 1/a, 1/a
+		for n := 1; n < specialIters; n++ {
+			del *= x / (a + float64(n))
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*specialEps {
+				break
+			}
+		}
+		return sum * front
+	}
+	// Q(a, x) por fração contínua
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1; i < specialIters; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < specialEps {
+			break
+		}
+	}
+	return 1 - front*h
+}
+
+// betaI é a função beta incompleta regularizada I_x(a, b).
+func betaI(a, b, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	lab, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log1p(-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF avalia a fração contínua de betaI.
+func betaCF(a, b, x float64) float64 {
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m < specialIters; m++ {
+		fm := float64(m)
+		for _, aa := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + aa*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + aa/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < specialEps {
+			break
+		}
+	}
+	return h
+}
+
+// chi2CDF é a função de distribuição do qui-quadrado com df graus de liberdade.
+func chi2CDF(x, df float64) float64 { return gammaP(df/2, x/2) }
+
+// tCDF é a função de distribuição do t de Student com df graus de liberdade.
+func tCDF(t, df float64) float64 {
+	// perto de t = 0, df/(df+t²) arredonda para 1; usa-se então a simetria
+	// I_x(a, b) = 1 - I_(1-x)(b, a), com 1-x calculado diretamente
+	x, y := df/(df+t*t), t*t/(df+t*t)
+	var tail float64
+	if x < y {
+		tail = betaI(df/2, 0.5, x) / 2
+	} else {
+		tail = (1 - betaI(0.5, df/2, y)) / 2
+	}
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// ppfArgs valida (p, df) de chi2_ppf e t_ppf.
+func ppfArgs(name string, a []float64) (p, df float64, err error) {
+	p, df = a[0], a[1]
+	if !(p > 0 && p < 1) {
+		return 0, 0, errors.New(msg("qnorm_p"))
+	}
+	if !(df > 0) || math.IsInf(df, 0) {
+		return 0, 0, fmt.Errorf(msg("needs_positive"), name, "df")
+	}
+	return p, df, nil
+}
+
+// invertCDF encontra x com cdf(x) = p por bisseção, partindo de [lo, hi] e
+// alargando o intervalo até conter a solução.
+func invertCDF(cdf func(float64) float64, p, lo, hi float64) float64 {
+	for cdf(hi) < p {
+		lo, hi = hi, 2*hi
+	}
+	for cdf(lo) > p {
+		hi, lo = lo, 2*lo
+	}
+	for hi-lo > ppfTol*math.Max(1, math.Abs(hi)) {
+		mid := (lo + hi) / 2
+		if cdf(mid) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+func chi2PPF(_ *EvalContext, a ...float64) (float64, error) {
+	p, df, err := ppfArgs("chi2_ppf", a)
+	if err != nil {
+		return 0, err
+	}
+	return invertCDF(func(x float64) float64 { return chi2CDF(x, df) }, p, 0, math.Max(1, df)), nil
+}
+
+// tPPF usa a simetria da distribuição t: procura sempre a cauda esquerda,
+// com q = min(p, 1-p), e troca o sinal para p > 0.5. Assim t_ppf(0.5, df) é
+// exatamente 0 e t_ppf(1-p, df) = -t_ppf(p, df).
+func tPPF(_ *EvalContext, a ...float64) (float64, error) {
+	p, df, err := ppfArgs("t_ppf", a)
+	if err != nil {
+		return 0, err
+	}
+	if p == 0.5 {
+		return 0, nil
+	}
+	q := math.Min(p, 1-p)
+	t := invertCDF(func(x float64) float64 { return tCDF(x, df) }, q, -1, 0)
+	if p > 0.5 {
+		return -t, nil
+	}
+	return t, nil
+}
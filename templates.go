@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Fórmulas com nome: ":def area := pi * r^2" guarda a fórmula e
+// "area where r=5" avalia-a com r = 5. Os parâmetros são os identificadores
+// da expressão que não são constantes nem ans; os que não forem dados em
+// where usam as variáveis da sessão.
+
+// template é uma fórmula guardada com :def.
+type template struct {
+	expr   string
+	params []string
+}
+
+var (
+	defRe   = regexp.MustCompile(`^([\pL_][\pL\pN_]*)\s*:=\s*(.+)$`)
+	whereRe = regexp.MustCompile(`(?i)^([\pL_][\pL\pN_]*)\s+where\s+(.+)$`)
+)
+
+// define trata ":def nome := expr"; sem argumentos lista as fórmulas.
+func (s *Session) define(arg string) error {
+	if arg == "" {
+		names := make([]string, 0, len(s.templates))
+		for n := range s.templates {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			t := s.templates[n]
+			fmt.Fprintf(s.out, "  %s(%s) := %s\n", n, strings.Join(t.params, ", "), t.expr)
+		}
+		return nil
+	}
+	m := defRe.FindStringSubmatch(arg)
+	if m == nil {
+		return errors.New(msg("usage_def"))
+	}
+	name := strings.ToLower(m[1])
+	if _, ok := functions[name]; ok {
+		return fmt.Errorf(msg("def_reserved"), name)
+	}
+	if _, ok := constants[name]; ok || name == "ans" {
+		return fmt.Errorf(msg("def_reserved"), name)
+	}
+	toks, err := tokenize(m[2], s.ctx)
+	if err != nil {
+		return err
+	}
+	rpn, err := shuntingYard(toks, s.ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := RPNToInfix(rpn); err != nil {
+		return err
+	}
+	var params []string
+	seen := map[string]bool{}
+	for _, t := range toks {
+		if _, ok := constants[t.val]; t.typ != tIdent || ok || t.val == "ans" || seen[t.val] {
+			continue
+		}
+		seen[t.val] = true
+		params = append(params, t.val)
+	}
+	s.templates[name] = template{expr: strings.TrimSpace(m[2]), params: params}
+	fmt.Fprintf(s.out, "%s(%s) := %s\n", name, strings.Join(params, ", "), s.templates[name].expr)
+	return nil
+}
+
+// splitTopLevel divide s em sep fora de parênteses, para que
+// "m=max(1,2), v=3" dê duas atribuições.
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// evalWhere avalia "nome where p1=v1, p2=v2" com os parâmetros ligados só
+// durante a avaliação. Devolve ok=false se a linha não é desta forma.
+func (s *Session) evalWhere(line string) (results []result, ok bool, err error) {
+	m := whereRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false, nil
+	}
+	t, ok := s.templates[strings.ToLower(m[1])]
+	if !ok {
+		return nil, false, nil
+	}
+	sep := ','
+	if s.ctx.decimalComma {
+		sep = ';'
+	}
+	var restores []func()
+	defer func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+		s.ctx.invalidateCache()
+	}()
+	logged := len(s.logged)
+	for _, b := range splitTopLevel(m[2], sep) {
+		name, expr, found := strings.Cut(b, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !found || !slices.Contains(t.params, name) {
+			return nil, true, fmt.Errorf(msg("def_param"), strings.TrimSpace(b), strings.Join(t.params, ", "))
+		}
+		v, err := s.eval(expr)
+		if err != nil {
+			return nil, true, err
+		}
+		restore, err := bindVar(s.ctx, name)
+		if err != nil {
+			return nil, true, err
+		}
+		restores = append(restores, restore)
+		s.ctx.setVar(name, v)
+	}
+	// os valores dos parâmetros não são resultados da linha
+	s.logged = s.logged[:logged]
+	s.ctx.invalidateCache()
+	results, err = s.evalAll(t.expr)
+	return results, true, err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// :watch expr — durante os ciclos (sigma, pi_prod, :sum, eval ... for x
+// from ...) mostra o valor de expr a cada passo. Num terminal com ANSI a
+// linha é reescrita no mesmo sítio; com a saída redirecionada escreve-se uma
+// linha por atualização.
+
+// watchInterval é o tempo mínimo entre duas atualizações da linha, para não
+// escrever um milhão de linhas num sigma grande.
+const watchInterval = 50 * time.Millisecond
+
+// ansiSupported diz se a saída é um terminal que entende sequências ANSI.
+func ansiSupported() bool {
+	if t := os.Getenv("TERM"); t == "" || t == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// statusLine é uma linha de estado que se reescreve no mesmo sítio.
+type statusLine struct {
+	out   io.Writer
+	ansi  bool
+	shown bool // a última linha escrita é a de estado
+	last  time.Time
+}
+
+// update mostra text; sem force, ignora atualizações demasiado próximas.
+func (l *statusLine) update(text string, force bool) {
+	if !force && time.Since(l.last) < watchInterval {
+		return
+	}
+	l.last = time.Now()
+	if l.ansi && l.shown {
+		fmt.Fprint(l.out, "\033[1A\033[2K") // sobe uma linha e apaga-a
+	}
+	fmt.Fprintln(l.out, text)
+	l.shown = true
+}
+
+// done deixa a linha como está; a próxima atualização começa uma nova.
+func (l *statusLine) done() { l.shown = false }
+
+// watcher é a expressão de :watch, já compilada; nil quando desligado.
+type watcher struct {
+	expr   string
+	rpn    []token
+	line   statusLine
+	active bool // a avaliar a própria expressão, que pode ter um sigma
+}
+
+// update avalia a expressão no estado atual do ciclo e mostra-a.
+func (w *watcher) update(ctx *EvalContext, force bool) {
+	if w == nil || w.active {
+		return
+	}
+	if !force && time.Since(w.line.last) < watchInterval {
+		return
+	}
+	w.active = true
+	notes := ctx.notes
+	v, err := evalRPN(w.rpn, ctx)
+	ctx.notes = notes
+	w.active = false
+	text := fmt.Sprintf("  [watch] %s = %.15g", w.expr, v)
+	if err != nil {
+		text = fmt.Sprintf("  [watch] %s: %v", w.expr, err)
+	}
+	w.line.update(text, force)
+}
+
+// finish mostra o valor final de um ciclo e fixa a linha.
+func (w *watcher) finish(ctx *EvalContext) {
+	if w == nil || w.active {
+		return
+	}
+	w.update(ctx, true)
+	w.line.done()
+}
+
+// setWatch trata ":watch expr" e ":watch off"; sem argumento mostra o atual.
+func (s *Session) setWatch(arg string) error {
+	switch arg {
+	case "":
+		if s.ctx.watch != nil {
+			fmt.Fprintln(s.out, "watch:", s.ctx.watch.expr)
+		} else {
+			fmt.Fprintln(s.out, "watch: off")
+		}
+		return nil
+	case "off":
+		s.ctx.watch = nil
+		fmt.Fprintln(s.out, "watch: off")
+		return nil
+	}
+	rpn, err := compile(arg, s.ctx)
+	if err != nil {
+		return err
+	}
+	s.ctx.watch = &watcher{expr: arg, rpn: rpn, line: statusLine{out: s.out, ansi: s.out == io.Writer(os.Stdout) && ansiSupported()}}
+	fmt.Fprintln(s.out, "watch:", arg)
+	return nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Modo :units — análise dimensional. "5 [m]" é um valor com unidade e as
+// unidades acompanham as contas: 5 [m] * 3 [s] = 15 [m·s], e somar metros
+// com segundos é um erro. Cada unidade é um vetor de expoentes racionais nas
+// unidades de base do SI; as derivadas (N, J, W, ...) são atalhos para esses
+// vetores, sem fatores de escala (não há km nem g).
+
+// baseUnits são as unidades de base do SI, pela ordem do vetor.
+var baseUnits = []string{"kg", "m", "s", "A", "K", "mol", "cd"}
+
+// frac é um expoente racional n/d, com d > 0 e a fração irredutível.
+type frac struct{ n, d int }
+
+func gcdInt(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return max(a, -a)
+}
+
+func newFrac(n, d int) frac {
+	if d < 0 {
+		n, d = -n, -d
+	}
+	g := gcdInt(n, d)
+	if g == 0 {
+		return frac{0, 1}
+	}
+	return frac{n / g, d / g}
+}
+
+func (f frac) String() string {
+	if f.d == 1 {
+		return strconv.Itoa(f.n)
+	}
+	return fmt.Sprintf("(%d/%d)", f.n, f.d)
+}
+
+// UnitVector guarda o expoente de cada unidade de base.
+type UnitVector [7]frac
+
+// dimensionless é a unidade dos números sem unidade.
+var dimensionless = UnitVector{{0, 1}, {0, 1}, {0, 1}, {0, 1}, {0, 1}, {0, 1}, {0, 1}}
+
+func (u UnitVector) mul(v UnitVector) UnitVector {
+	for i := range u {
+		u[i] = newFrac(u[i].n*v[i].d+v[i].n*u[i].d, u[i].d*v[i].d)
+	}
+	return u
+}
+
+// pow eleva a unidade a k; dividir é multiplicar por v.pow(-1).
+func (u UnitVector) pow(k frac) UnitVector {
+	for i := range u {
+		u[i] = newFrac(u[i].n*k.n, u[i].d*k.d)
+	}
+	return u
+}
+
+// String escreve a unidade como kg·m/s^2; vazia se não tem dimensão.
+func (u UnitVector) String() string {
+	var num, den []string
+	for i, e := range u {
+		switch {
+		case e.n == 0:
+		case e.n > 0 && e == (frac{1, 1}):
+			num = append(num, baseUnits[i])
+		case e.n > 0:
+			num = append(num, baseUnits[i]+"^"+e.String())
+		case e == (frac{-1, 1}):
+			den = append(den, baseUnits[i])
+		default:
+			den = append(den, baseUnits[i]+"^"+newFrac(-e.n, e.d).String())
+		}
+	}
+	switch {
+	case len(den) == 0:
+		return strings.Join(num, "·")
+	case len(num) == 0:
+		return "1/" + strings.Join(den, "·")
+	}
+	return strings.Join(num, "·") + "/" + strings.Join(den, "·")
+}
+
+// namedUnits são as unidades que se podem escrever entre parênteses retos.
+var namedUnits = map[string]UnitVector{}
+
+func init() {
+	for i, b := range baseUnits {
+		u := dimensionless
+		u[i] = frac{1, 1}
+		namedUnits[b] = u
+	}
+	derived := []struct{ name, def string }{
+		{"N", "kg·m/s^2"}, {"J", "N·m"}, {"W", "J/s"}, {"Pa", "N/m^2"},
+		{"Hz", "1/s"}, {"C", "A·s"}, {"V", "W/A"}, {"Ohm", "V/A"},
+	}
+	for _, d := range derived {
+		u, err := parseUnit(d.def)
+		if err != nil {
+			panic(err)
+		}
+		namedUnits[d.name] = u
+	}
+}
+
+// parseUnit lê o texto entre parênteses retos: fatores separados por ·, * ou
+// espaços, cada um com expoente inteiro opcional (s^2, s^-1); um / divide
+// pelo fator seguinte, como em m/s ou kg/m/s^2.
+func parseUnit(s string) (UnitVector, error) {
+	u := dimensionless
+	s = strings.NewReplacer("·", " ", "*", " ", "/", " / ").Replace(s)
+	div := false
+	factors := 0
+	for _, f := range strings.Fields(s) {
+		if f == "/" {
+			div = true
+			continue
+		}
+		name, exp, hasExp := strings.Cut(f, "^")
+		k := 1
+		if hasExp {
+			var err error
+			if k, err = strconv.Atoi(exp); err != nil {
+				return u, fmt.Errorf(msg("unit_unknown"), f)
+			}
+		}
+		if div {
+			k, div = -k, false
+		}
+		factors++
+		if name == "1" && !hasExp {
+			continue // 1/s
+		}
+		base, ok := namedUnits[name]
+		if !ok {
+			return u, fmt.Errorf(msg("unit_unknown"), name)
+		}
+		u = u.mul(base.pow(frac{k, 1}))
+	}
+	if factors == 0 || div {
+		return u, fmt.Errorf(msg("unit_unknown"), strings.TrimSpace(s))
+	}
+	return u, nil
+}
+
+// scanUnit lê "[unidade]" a partir de s[i] == '['; devolve o texto e a
+// posição a seguir ao ']'.
+func scanUnit(s string, i int) (string, int, error) {
+	end := strings.IndexByte(s[i:], ']')
+	if end < 0 {
+		return "", 0, errAt(i, errors.New(msg("unit_unclosed")))
+	}
+	text := strings.TrimSpace(s[i+1 : i+end])
+	if _, err := parseUnit(text); err != nil {
+		return "", 0, errAt(i, err)
+	}
+	return text, i + end + 1, nil
+}
+
+// quantity é um valor com unidade.
+type quantity struct {
+	v float64
+	u UnitVector
+}
+
+// isUnit diz se o identificador é uma unidade vinda do tokenizador.
+func isUnit(t token) bool { return t.typ == tIdent && strings.HasPrefix(t.val, "[") }
+
+// maxUnitDenom é o maior denominador aceite num expoente: sqrt dá 1/2, cbrt
+// 1/3, x^0.25 dá 1/4.
+const maxUnitDenom = 12
+
+// toFrac converte um expoente em float64 numa fração de denominador pequeno.
+func toFrac(x float64) (frac, bool) {
+	for d := 1; d <= maxUnitDenom; d++ {
+		n := math.Round(x * float64(d))
+		if math.Abs(n-x*float64(d)) < 1e-9 && math.Abs(n) < 1<<20 {
+			return newFrac(int(n), d), true
+		}
+	}
+	return frac{}, false
+}
+
+// evalUnits avalia a forma pós-fixa levando as unidades ao lado dos valores.
+func evalUnits(rpn []token, ctx *EvalContext) (quantity, error) {
+	var st []quantity
+	pop := func(n int) []quantity {
+		args := append([]quantity(nil), st[len(st)-n:]...)
+		st = st[:len(st)-n]
+		return args
+	}
+	for _, t := range rpn {
+		switch {
+		case isUnit(t):
+			u, err := parseUnit(t.val[1 : len(t.val)-1])
+			if err != nil {
+				return quantity{}, errAt(t.offset, err)
+			}
+			st = append(st, quantity{1, u})
+		case t.typ == tIdent && t.val == "ans" && ctx.unitAns != nil:
+			st = append(st, quantity{ctx.lastAns, *ctx.unitAns})
+		case t.typ == tIdent && ctx.varResults[t.val].unit != nil:
+			r := ctx.varResults[t.val]
+			st = append(st, quantity{r.val, *r.unit})
+		case t.typ == tNumber, t.typ == tIdent:
+			// constantes e variáveis sem unidade guardada
+			v, err := evalRPN([]token{t}, ctx)
+			if err != nil {
+				return quantity{}, err
+			}
+			st = append(st, quantity{v, dimensionless})
+		case t.typ == tOp && ops[t.val].unary:
+			if len(st) < 1 {
+				return quantity{}, errAt(t.offset, errors.New(msg("unary_no_operand")))
+			}
+			st[len(st)-1].v = ops[t.val].fn(0, st[len(st)-1].v)
+		case t.typ == tOp:
+			if len(st) < 2 {
+				return quantity{}, errAt(t.offset, errors.New(msg("binary_few_operands")))
+			}
+			ab := pop(2)
+			res, err := unitsBinary(t, ab[0], ab[1])
+			if err != nil {
+				return quantity{}, errAt(t.offset, err)
+			}
+			st = append(st, res)
+		case t.typ == tFunc:
+			if t.lazy != nil {
+				return quantity{}, errAt(t.offset, fmt.Errorf(msg("unit_dimensionless"), t.val))
+			}
+			nargs, err := argCount(t)
+			if err != nil {
+				return quantity{}, err
+			}
+			if len(st) < nargs {
+				return quantity{}, errAt(t.offset, fmt.Errorf(msg("func_few_args"), t.val))
+			}
+			res, err := unitsFunc(t.val, pop(nargs), ctx)
+			if err != nil {
+				return quantity{}, errAt(t.offset, err)
+			}
+			st = append(st, res)
+		}
+	}
+	if len(st) != 1 {
+		return quantity{}, errors.New(msg("invalid_expr"))
+	}
+	return st[0], nil
+}
+
+func unitsBinary(t token, a, b quantity) (quantity, error) {
+	switch t.val {
+	case "+", "-":
+		if a.u != b.u {
+			return quantity{}, fmt.Errorf(msg("unit_mismatch"), unitLabel(a.u), t.val, unitLabel(b.u))
+		}
+		return quantity{ops[t.val].fn(a.v, b.v), a.u}, nil
+	case "*", "of":
+		return quantity{a.v * b.v, a.u.mul(b.u)}, nil
+	case "/", "//":
+		if b.v == 0 {
+			return quantity{}, errors.New(msg("division_by_zero"))
+		}
+		return quantity{ops[t.val].fn(a.v, b.v), a.u.mul(b.u.pow(frac{-1, 1}))}, nil
+	case "^":
+		if b.u != dimensionless {
+			return quantity{}, fmt.Errorf(msg("unit_dimensionless"), "^")
+		}
+		if a.u == dimensionless {
+			return quantity{math.Pow(a.v, b.v), a.u}, nil
+		}
+		k, ok := toFrac(b.v)
+		if !ok {
+			return quantity{}, fmt.Errorf(msg("unit_exponent"), b.v)
+		}
+		return quantity{math.Pow(a.v, b.v), a.u.pow(k)}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		// só se comparam grandezas com a mesma dimensão; o resultado (0 ou
+		// 1) não tem unidade
+		if a.u != b.u {
+			return quantity{}, fmt.Errorf(msg("unit_mismatch"), unitLabel(a.u), t.val, unitLabel(b.u))
+		}
+		return quantity{ops[t.val].fn(a.v, b.v), dimensionless}, nil
+	}
+	if a.u != dimensionless || b.u != dimensionless {
+		return quantity{}, fmt.Errorf(msg("unit_dimensionless"), t.val)
+	}
+	return quantity{ops[t.val].fn(a.v, b.v), a.u}, nil
+}
+
+// unitsFunc: sqrt e cbrt tiram a raiz à unidade, abs, floor, ... mantêm-na,
+// if (e c ? a : b) fica com a do ramo escolhido; as restantes só aceitam
+// números sem unidade.
+func unitsFunc(name string, a []quantity, ctx *EvalContext) (quantity, error) {
+	args := make([]float64, len(a))
+	for i, q := range a {
+		args[i] = q.v
+	}
+	u := dimensionless
+	switch name {
+	case "sqrt":
+		u = a[0].u.pow(frac{1, 2})
+	case "cbrt":
+		u = a[0].u.pow(frac{1, 3})
+	case "if":
+		if a[0].u != dimensionless {
+			return quantity{}, fmt.Errorf(msg("unit_dimensionless"), name)
+		}
+		if a[0].v != 0 {
+			return a[1], nil
+		}
+		return a[2], nil
+	case "abs", "floor", "ceil", "round", "max", "min":
+		u = a[0].u
+		for _, q := range a[1:] {
+			if q.u != u {
+				return quantity{}, fmt.Errorf(msg("unit_mismatch"), unitLabel(u), name, unitLabel(q.u))
+			}
+		}
+	default:
+		for _, q := range a {
+			if q.u != dimensionless {
+				return quantity{}, fmt.Errorf(msg("unit_dimensionless"), name)
+			}
+		}
+	}
+	v, err := functions[name].fn(ctx, args...)
+	return quantity{v, u}, err
+}
+
+// unitLabel escreve a unidade para as mensagens: [m], ou [] sem unidade.
+func unitLabel(u UnitVector) string { return "[" + u.String() + "]" }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// "!x=5" — e se? Volta a avaliar a última expressão com x = 5, sem mudar as
+// variáveis da sessão. Também serve para constantes: !pi=3.
+
+// substitute troca cada ocorrência do identificador name por um número.
+func substitute(toks []token, name string, v float64) ([]token, bool) {
+	out := make([]token, len(toks))
+	found := false
+	for i, t := range toks {
+		if t.typ == tIdent && t.val == name {
+			t = token{typ: tNumber, val: strconv.FormatFloat(v, 'g', -1, 64), offset: t.offset}
+			found = true
+		}
+		out[i] = t
+	}
+	return out, found
+}
+
+// whatIf trata "!nome=expr": a substituição é feita nos tokens, antes do
+// shunting-yard, por isso vale também dentro de sigma e companhia.
+func (s *Session) whatIf(arg string) ([]result, error) {
+	name, expr, ok := strings.Cut(arg, "=")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !ok || name == "" {
+		return nil, errors.New(msg("usage_whatif"))
+	}
+	if s.lastExpr == "" {
+		return nil, errors.New(msg("whatif_none"))
+	}
+	v, err := evalExpr(expr, s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := tokenize(s.lastExpr, s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	toks, found := substitute(toks, name, v)
+	if !found {
+		return nil, fmt.Errorf(msg("whatif_absent"), name, s.lastExpr)
+	}
+	fmt.Fprintf(s.out, "  %s  [%s = %s]\n", s.lastExpr, name, s.formatValue(v))
+	rpns, err := compileTokens(toks, s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.evalCompiled(rpns)
+}
+
+// maxRepeat é o maior N aceite por :repeat.
+const maxRepeat = 10000
+
+// repeat trata ":repeat N": volta a executar N vezes a última linha, seja
+// uma expressão ou uma atribuição; o último resultado fica em ans.
+func (s *Session) repeat(arg string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 1 || n > maxRepeat {
+		return fmt.Errorf(msg("usage_repeat"), maxRepeat)
+	}
+	if s.lastLine == "" {
+		return errors.New(msg("whatif_none"))
+	}
+	line := s.lastLine
+	for i := 0; i < n; i++ {
+		if _, err := s.execLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}